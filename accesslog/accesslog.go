@@ -0,0 +1,181 @@
+// Package accesslog logs every request that a Router sends a response to.
+// Logger is a routing.Observer, which writes an entry once the response has
+// been sent, so that the entry has the real status and amount of bytes sent:
+//
+//	router.RegisterObserver(accesslog.NewLogger(os.Stdout, accesslog.CombinedFormat))
+package accesslog
+
+import (
+	"den/routing"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is a single request in the access log.
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remote_addr"`
+	User       string        `json:"user,omitempty"`
+	Method     string        `json:"method"`
+	Endpoint   string        `json:"endpoint"`
+	Path       string        `json:"path"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Duration   time.Duration `json:"duration"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+}
+
+// NewEntry creates the entry of a request, from the event of the request
+// ending routing.StageSend.
+func NewEntry(event routing.StageEvent) Entry {
+	req := event.Request
+
+	entry := Entry{
+		Time:       event.Received,
+		RemoteAddr: req.RemoteAddr,
+		Method:     event.Method,
+		Endpoint:   event.Endpoint,
+		Proto:      req.Proto,
+		Status:     event.Status,
+		Bytes:      event.BytesSent,
+		Duration:   time.Since(event.Received),
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+	}
+
+	if req.URL != nil {
+		entry.Path = req.URL.RequestURI()
+	}
+
+	if user, _, ok := req.BasicAuth(); ok {
+		entry.User = user
+	}
+
+	return entry
+}
+
+// Format is the format that a Logger writes entries in.
+type Format int
+
+const (
+	// CommonFormat is the Common Log Format, as used by most web servers:
+	//
+	//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326
+	CommonFormat Format = iota
+	// CombinedFormat is the Common Log Format, followed by
+	// the referer and user agent of the request.
+	CombinedFormat
+	// JSONFormat writes every entry as a JSON object on its own line, with the
+	// fields of Entry. The duration is in seconds, and the time is in RFC 3339.
+	JSONFormat
+)
+
+// clfTime is the layout of times in the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// Append appends the entry, in this format, to buf. There is no
+// newline at the end of the entry.
+func (f Format) Append(buf []byte, e Entry) []byte {
+	if f == JSONFormat {
+		return e.appendJSON(buf)
+	}
+
+	host := e.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	buf = append(buf, orDash(host)...)
+	buf = append(buf, " - "...)
+	buf = append(buf, orDash(e.User)...)
+	buf = append(buf, " ["...)
+	buf = e.Time.AppendFormat(buf, clfTime)
+	buf = append(buf, "] "...)
+	buf = strconv.AppendQuote(buf, e.Method+" "+e.Path+" "+e.Proto)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(e.Status), 10)
+	buf = append(buf, ' ')
+
+	if e.Bytes > 0 {
+		buf = strconv.AppendInt(buf, e.Bytes, 10)
+	} else {
+		buf = append(buf, '-')
+	}
+
+	if f == CombinedFormat {
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, orDash(e.Referer))
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, orDash(e.UserAgent))
+	}
+
+	return buf
+}
+
+func (e Entry) appendJSON(buf []byte) []byte {
+	// the duration is written in seconds, rather than nanoseconds
+	type entry Entry
+	data, err := json.Marshal(struct {
+		entry
+		Duration float64 `json:"duration"`
+	}{entry(e), e.Duration.Seconds()})
+
+	if err != nil {
+		return buf
+	}
+
+	return append(buf, data...)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// Logger writes an entry for every request that a Router sends a response
+// to. Register it to a Router through routing.Router.RegisterObserver.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+	buf    []byte
+}
+
+// NewLogger creates a new Logger, which writes entries to w in the given format.
+func NewLogger(w io.Writer, format Format) *Logger {
+	return &Logger{
+		w:      w,
+		format: format,
+	}
+}
+
+func (l *Logger) StageStart(routing.StageEvent) {}
+
+// StageEnd writes the entry of the request, once its response has been sent.
+func (l *Logger) StageEnd(event routing.StageEvent) {
+	if event.Stage != routing.StageSend {
+		return
+	}
+
+	l.Log(NewEntry(event))
+}
+
+// Log writes a single entry to the log. Errors from writing
+// the entry are ignored, as there's nowhere to report them to.
+func (l *Logger) Log(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = l.format.Append(l.buf[:0], e)
+	l.buf = append(l.buf, '\n')
+	l.w.Write(l.buf)
+}
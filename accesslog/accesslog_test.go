@@ -0,0 +1,118 @@
+package accesslog
+
+import (
+	"bytes"
+	"den/routing"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRoute struct {
+	code int
+	body string
+}
+
+func (t *testRoute) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	resp := routing.CreateResponseInfo(t.code, nil, routing.Html, req.RequestEndpoint(), bytes.NewBufferString(t.body))
+	return &resp, nil
+}
+
+func logRequest(format Format, req *http.Request) string {
+	var buf bytes.Buffer
+
+	router := routing.NewRouter()
+	router.RegisterObserver(NewLogger(&buf, format))
+	router.RegisterRoute("users", &testRoute{http.StatusOK, "<p>hi</p>"})
+	router.RegisterRoute("empty", &testRoute{http.StatusNoContent, ""})
+
+	router.RouteRequest(httptest.NewRecorder(), req)
+
+	return buf.String()
+}
+
+func newRequest(rawUrl string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, rawUrl, nil)
+	req.RemoteAddr = "192.0.2.1:51234"
+	req.Header.Set("User-Agent", "test/1.0")
+	req.Header.Set("Referer", "https://test.org/")
+
+	return req
+}
+
+func TestLogger_CommonFormat(t *testing.T) {
+	req := newRequest("https://test.org/users/42?page=2")
+	req.SetBasicAuth("frank", "secret")
+
+	line := logRequest(CommonFormat, req)
+
+	expected := regexp.MustCompile(`^192\.0\.2\.1 - frank \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /users/42\?page=2 HTTP/1\.1" 200 9\n$`)
+	if !expected.MatchString(line) {
+		t.Fatalf("unexpected common log line: %q", line)
+	}
+}
+
+func TestLogger_CombinedFormat(t *testing.T) {
+	line := logRequest(CombinedFormat, newRequest("https://test.org/empty/"))
+
+	if !strings.HasPrefix(line, "192.0.2.1 - - [") {
+		t.Fatalf("expected an anonymous request from 192.0.2.1, got %q", line)
+	}
+
+	if !strings.HasSuffix(line, `"GET /empty/ HTTP/1.1" 204 - "https://test.org/" "test/1.0"`+"\n") {
+		t.Fatalf("expected no bytes, and the referer and user agent, got %q", line)
+	}
+}
+
+func TestLogger_JSONFormat(t *testing.T) {
+	line := logRequest(JSONFormat, newRequest("https://test.org/users/42"))
+
+	var entry struct {
+		Entry
+		Duration float64 `json:"duration"`
+	}
+
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("expected a JSON entry, got %q: %s", line, err)
+	}
+
+	if entry.RemoteAddr != "192.0.2.1:51234" || entry.Method != http.MethodGet || entry.Endpoint != "users" || entry.Path != "/users/42" {
+		t.Fatalf("unexpected request in entry: %+v", entry)
+	}
+
+	if entry.Status != http.StatusOK || entry.Bytes != 9 || entry.UserAgent != "test/1.0" {
+		t.Fatalf("unexpected response in entry: %+v", entry)
+	}
+
+	if entry.Time.IsZero() || entry.Duration < 0 {
+		t.Fatalf("expected the time and duration of the request, got %+v", entry)
+	}
+}
+
+func TestFormat_Append(t *testing.T) {
+	e := Entry{
+		Time:       time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		RemoteAddr: "127.0.0.1",
+		User:       "frank",
+		Method:     http.MethodGet,
+		Path:       "/a.gif",
+		Proto:      "HTTP/1.0",
+		Status:     http.StatusOK,
+		Bytes:      2326,
+		UserAgent:  `say "hi"`,
+	}
+
+	common := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326`
+	if line := string(CommonFormat.Append(nil, e)); line != common {
+		t.Fatalf("expected %s, got %s", common, line)
+	}
+
+	combined := common + ` "-" "say \"hi\""`
+	if line := string(CombinedFormat.Append(nil, e)); line != combined {
+		t.Fatalf("expected %s, got %s", combined, line)
+	}
+}
@@ -0,0 +1,6 @@
+module accesslog
+
+go 1.18
+
+require den/routing v0.0.0
+replace den/routing => ./../routing
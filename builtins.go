@@ -0,0 +1,115 @@
+package den
+
+import (
+	"den/files"
+	"den/pages"
+	"den/pages/handlers"
+	"den/routing"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Handlers that come with den, so that they can be referenced by name
+// in configuration files:
+//
+//	files   serves a directory through files.FileHandler
+//	pages   serves a page tree of files through pages.PageHandler
+func init() {
+	if err := Register("files", newFilesHandler); err != nil {
+		panic(err)
+	}
+
+	if err := Register("pages", newPagesHandler); err != nil {
+		panic(err)
+	}
+}
+
+type filesOptions struct {
+	// Path is the directory that files are served from.
+	Path string `yaml:"path" den:"required"`
+	// Cache maps an extension or path glob to the cache policy of the
+	// files that match it, see files.FileHandler.SetCachePolicy. Globs
+	// are checked in alphabetical order.
+	Cache map[string]files.CachePolicy `yaml:"cache"`
+	// Index are the files that are sent in place of a directory,
+	// see files.FileHandler.SetIndexFiles.
+	Index []string `yaml:"index" default:"[index.html]"`
+	// Listings is whether directories without an index file are listed.
+	Listings bool `yaml:"listings" default:"false"`
+	// HideDotfiles hides files whose name starts with a dot,
+	// see files.FileHandler.SetHideDotfiles.
+	HideDotfiles bool `yaml:"hideDotfiles"`
+}
+
+func (o *filesOptions) Validate() error {
+	return checkDir(o.Path)
+}
+
+func newFilesHandler(o filesOptions) (routing.RouteHandler, error) {
+	handler := files.NewFileHandler(o.Path)
+	handler.SetIndexFiles(o.Index...)
+	handler.SetListings(o.Listings)
+	handler.SetHideDotfiles(o.HideDotfiles)
+
+	patterns := make([]string, 0, len(o.Cache))
+	for pattern := range o.Cache {
+		patterns = append(patterns, pattern)
+	}
+
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if err := handler.SetCachePolicy(pattern, o.Cache[pattern]); err != nil {
+			return nil, err
+		}
+	}
+
+	return handler, nil
+}
+
+type pagesOptions struct {
+	// Pages maps a slash-separated path in the page tree to a file on disk.
+	Pages map[string]string `yaml:"pages" den:"required"`
+	// Type is the response type of every page.
+	Type string `yaml:"type" default:"html"`
+}
+
+func (o *pagesOptions) Validate() error {
+	if _, err := routing.ParseResponseType(o.Type); err != nil {
+		return err
+	}
+
+	for _, path := range o.Pages {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newPagesHandler(o pagesOptions) (routing.RouteHandler, error) {
+	handler := pages.NewPageHandler()
+	responseType, _ := routing.ParseResponseType(o.Type)
+	handler.SetResponseType(responseType)
+
+	for page, path := range o.Pages {
+		handler.AddPage(page, handlers.NewFilePageNodeHandler(page, path))
+	}
+
+	return handler, nil
+}
+
+func checkDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	return nil
+}
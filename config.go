@@ -0,0 +1,404 @@
+package den
+
+import (
+	"bytes"
+	"den/routing"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a declarative description of a site. Every handler in the
+// configuration is referenced by the name it was stored under in
+// RoutingHandlers, and is created with the options given next to it.
+//
+// An example configuration:
+//
+//	address: 127.0.0.1:8080
+//	timeout: 30s
+//	production: true
+//	routes:
+//	  - endpoint: static
+//	    handler: files
+//	    options:
+//	      path: ./public
+//	requestProcessors:
+//	  - method: GET
+//	    processor: auth
+//	responseProcessors:
+//	  - type: html
+//	    endpoint: blog
+//	    processor: minify
+//
+// The endpoint "/" refers to routing.EndpointRoot, and "*" refers to
+// routing.EndpointDefault. Processors are referenced by the name they were
+// stored under in RequestProcessors or ResponseProcessors. A processor can
+// also be given as a handler from RoutingHandlers instead, as long as the
+// handler implements routing.RequestProcessor or routing.ResponseProcessor.
+type Config struct {
+	// Address is the TCP address that the server will listen on.
+	Address string `yaml:"address"`
+	// Timeout is how long a single request may take, see
+	// routing.Router.SetTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// Production hides the internal details of errors from clients,
+	// see routing.Router.SetProductionMode.
+	Production bool `yaml:"production"`
+
+	Routes             []RouteConfig             `yaml:"routes"`
+	RequestProcessors  []RequestProcessorConfig  `yaml:"requestProcessors"`
+	ResponseProcessors []ResponseProcessorConfig `yaml:"responseProcessors"`
+
+	// file is the name of the file this config was parsed from,
+	// used for error reporting.
+	file string
+	// addressLine is the line that the address was found on, or
+	// the line that the config started on, if it has no address.
+	addressLine int
+}
+
+// HandlerConfig is the part of every configuration entry that refers
+// to a handler in RoutingHandlers.
+type HandlerConfig struct {
+	// Handler is the name of the handler in the database.
+	Handler string `yaml:"handler"`
+	// Options are passed into the handler's HandlerInit.
+	Options map[interface{}]interface{} `yaml:"options"`
+
+	// line is the line that this entry started on.
+	line int
+}
+
+// RouteConfig registers a handler to an endpoint.
+type RouteConfig struct {
+	Endpoint      string `yaml:"endpoint"`
+	HandlerConfig `yaml:",inline"`
+}
+
+// ProcessorConfig is the part of every processor entry that refers to
+// either a processor, or a handler that can be used as one.
+type ProcessorConfig struct {
+	// Processor is the name of the processor in its database.
+	Processor     string `yaml:"processor"`
+	HandlerConfig `yaml:",inline"`
+}
+
+// RequestProcessorConfig registers a request processor for an HTTP method.
+type RequestProcessorConfig struct {
+	Method          string `yaml:"method"`
+	ProcessorConfig `yaml:",inline"`
+}
+
+// ResponseProcessorConfig registers a response processor for a response
+// type and the endpoint it originates from.
+type ResponseProcessorConfig struct {
+	Type            string `yaml:"type"`
+	Endpoint        string `yaml:"endpoint"`
+	ProcessorConfig `yaml:",inline"`
+}
+
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkFields(value, c); err != nil {
+		return err
+	}
+
+	type plain Config
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	c.addressLine = value.Line
+
+	// a mapping's content alternates between keys and values
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "address" {
+			c.addressLine = value.Content[i+1].Line
+		}
+	}
+
+	return nil
+}
+
+func (c *RouteConfig) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkFields(value, c); err != nil {
+		return err
+	}
+
+	type plain RouteConfig
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	c.line = value.Line
+	return nil
+}
+
+func (c *RequestProcessorConfig) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkFields(value, c); err != nil {
+		return err
+	}
+
+	type plain RequestProcessorConfig
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	c.line = value.Line
+	return nil
+}
+
+func (c *ResponseProcessorConfig) UnmarshalYAML(value *yaml.Node) error {
+	if err := checkFields(value, c); err != nil {
+		return err
+	}
+
+	type plain ResponseProcessorConfig
+	if err := value.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	c.line = value.Line
+	return nil
+}
+
+// checkFields checks that every key of a mapping is the name of a field of
+// the struct that v points to, including the fields of any inlined structs.
+// Since each entry of a configuration decodes itself, the decoder's own
+// KnownFields doesn't reach into it.
+func checkFields(value *yaml.Node, v interface{}) error {
+	if value.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	fields := yamlFields(reflect.TypeOf(v).Elem())
+
+	// a mapping's content alternates between keys and values
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key := value.Content[i]
+
+		if !fields[key.Value] {
+			return &ConfigError{Line: key.Line, Err: fmt.Errorf("unknown field %q", key.Value)}
+		}
+	}
+
+	return nil
+}
+
+// yamlFields gets the names that the fields of a struct are decoded from.
+func yamlFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		name, flags, _ := strings.Cut(tag, ",")
+
+		switch {
+		case strings.Contains(flags, "inline"):
+			for name := range yamlFields(field.Type) {
+				fields[name] = true
+			}
+		case !field.IsExported() || name == "-":
+			continue
+		case name == "":
+			fields[strings.ToLower(field.Name)] = true
+		default:
+			fields[name] = true
+		}
+	}
+
+	return fields
+}
+
+// ConfigError is an error found at a specific line of a configuration file.
+type ConfigError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors is every error found while building a configuration.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// LoadConfig reads the YAML configuration at the given path, and builds
+// a Server from it.
+func LoadConfig(path string) (*Server, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Build()
+}
+
+// ParseConfig parses a YAML configuration. The name is only used for
+// error reporting, and is usually the path of the configuration file.
+// Keys that aren't part of the configuration are returned as a ConfigError,
+// rather than ignored, so that a misspelled key can't go unnoticed.
+func ParseConfig(name string, data []byte) (*Config, error) {
+	config := new(Config)
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(config); err != nil && err != io.EOF {
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			configErr.File = name
+			return nil, configErr
+		}
+
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	config.file = name
+
+	return config, nil
+}
+
+// Build creates a new Server from the configuration, creating every
+// handler from RoutingHandlers and registering it to the server's router.
+// Every error in the configuration is returned as ConfigErrors.
+func (c *Config) Build() (*Server, error) {
+	var errs ConfigErrors
+
+	fail := func(line int, err error) {
+		errs = append(errs, &ConfigError{c.file, line, err})
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", c.Address)
+	if c.Address == "" {
+		fail(c.addressLine, fmt.Errorf("no address given"))
+	} else if err != nil {
+		fail(c.addressLine, err)
+	}
+
+	server := NewServer(addr)
+	server.Router.SetTimeout(c.Timeout)
+	server.Router.SetProductionMode(c.Production)
+
+	for _, r := range c.Routes {
+		handler, err := RoutingHandlers.create(r.Handler, r.Options)
+		if err != nil {
+			fail(r.line, err)
+			continue
+		}
+
+		server.Router.RegisterRoute(configEndpoint(r.Endpoint), handler)
+	}
+
+	for _, p := range c.RequestProcessors {
+		if p.Method == "" {
+			fail(p.line, fmt.Errorf("no method given for request processor %q", p.name()))
+			continue
+		}
+
+		processor, err := createProcessor(&RequestProcessors, p.ProcessorConfig)
+		if err != nil {
+			fail(p.line, err)
+			continue
+		}
+
+		server.Router.RegisterRequestProcessor(strings.ToUpper(p.Method), processor)
+	}
+
+	for _, p := range c.ResponseProcessors {
+		responseType, err := routing.ParseResponseType(p.Type)
+		if err != nil {
+			fail(p.line, err)
+			continue
+		}
+
+		processor, err := createProcessor(&ResponseProcessors, p.ProcessorConfig)
+		if err != nil {
+			fail(p.line, err)
+			continue
+		}
+
+		server.Router.RegisterResponseProcessor(responseType, configEndpoint(p.Endpoint), processor)
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return server, nil
+}
+
+// name is the name of whatever this entry refers to.
+func (c *ProcessorConfig) name() string {
+	if c.Processor != "" {
+		return c.Processor
+	}
+
+	return c.Handler
+}
+
+// createProcessor creates a processor from its database, or from
+// RoutingHandlers if the entry refers to a handler instead.
+func createProcessor[T any](db *Database[T], c ProcessorConfig) (T, error) {
+	var zero T
+
+	switch {
+	case c.Processor != "" && c.Handler != "":
+		return zero, fmt.Errorf("only one of processor %q and handler %q can be given", c.Processor, c.Handler)
+	case c.Processor != "":
+		return db.create(c.Processor, c.Options)
+	case c.Handler == "":
+		return zero, errors.New("no processor given")
+	}
+
+	handler, err := RoutingHandlers.create(c.Handler, c.Options)
+	if err != nil {
+		return zero, err
+	}
+
+	processor, ok := handler.(T)
+	if !ok {
+		return zero, fmt.Errorf("handler %q is not a %s", c.Handler, db.kindName())
+	}
+
+	return processor, nil
+}
+
+// configEndpoint translates an endpoint from a configuration file into
+// an endpoint that the router understands.
+func configEndpoint(endpoint string) string {
+	switch endpoint {
+	case "/":
+		return routing.EndpointRoot
+	case "*":
+		return routing.EndpointDefault
+	}
+
+	return strings.Trim(endpoint, "/")
+}
@@ -0,0 +1,297 @@
+package den
+
+import (
+	"bytes"
+	"den/routing"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// configRoute is a handler that can be used as every part of the pipeline,
+// and responds with whatever body it was configured with.
+type configRoute struct {
+	body string
+}
+
+func (c *configRoute) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	resp := routing.CreateResponseInfo(http.StatusOK, http.Header{}, routing.Text, req.RequestEndpoint(), bytes.NewBufferString(c.body))
+	return &resp, nil
+}
+
+func (c *configRoute) ProcessRequest(*http.Request) error {
+	return nil
+}
+
+func (c *configRoute) ProcessResponse(*routing.ResponseInfo) error {
+	return nil
+}
+
+// routeOnly is a handler that cannot be used as a processor.
+type routeOnly struct{}
+
+func (r *routeOnly) HandleRequest(*routing.RequestInfo) (*routing.ResponseInfo, error) {
+	return nil, nil
+}
+
+func init() {
+	RoutingHandlers.Add("test_config_route", func(options map[interface{}]interface{}) routing.RouteHandler {
+		return &configRoute{options["body"].(string)}
+	})
+
+	RoutingHandlers.Add("test_route_only", func(map[interface{}]interface{}) routing.RouteHandler {
+		return new(routeOnly)
+	})
+}
+
+func writeConfig(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "den.yaml")
+
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("error upon writing config: %s", err)
+	}
+
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+address: 127.0.0.1:8080
+timeout: 5s
+routes:
+  - endpoint: hello
+    handler: test_config_route
+    options:
+      body: Hello, world!
+requestProcessors:
+  - method: get
+    handler: test_config_route
+    options:
+      body: unused
+responseProcessors:
+  - type: text
+    endpoint: hello
+    handler: test_config_route
+    options:
+      body: unused
+`)
+
+	server, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected config to load, got: %s", err)
+	}
+
+	if server.Addr() != "127.0.0.1:8080" {
+		t.Fatalf("expected address 127.0.0.1:8080, got %s", server.Addr())
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/hello/", nil)
+
+	server.Router.RouteRequest(w, req)
+
+	if w.Body.String() != "Hello, world!" {
+		t.Fatalf("expected Hello, world! as body, got %s", w.Body.String())
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := writeConfig(t, `address: 127.0.0.1:8080
+routes:
+  - endpoint: hello
+    handler: does_not_exist
+  - endpoint: bad
+    handler: test_config_route
+    options:
+      body: 5
+responseProcessors:
+  - type: html
+    handler: test_route_only
+  - type: nothing
+    handler: test_config_route
+`)
+
+	_, err := LoadConfig(path)
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	expected := []int{3, 5, 10, 12}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %s", len(expected), len(errs), err)
+	}
+
+	for i, line := range expected {
+		if errs[i].Line != line {
+			t.Errorf("expected error %d on line %d, got line %d: %s", i, line, errs[i].Line, errs[i])
+		}
+
+		if !strings.HasPrefix(errs[i].Error(), path+":") {
+			t.Errorf("expected error to be prefixed with the file name, got %s", errs[i])
+		}
+	}
+}
+
+func TestLoadConfigBadOptions(t *testing.T) {
+	path := writeConfig(t, `address: 127.0.0.1:8080
+routes:
+  - endpoint: hello
+    handler: test_config_route
+    options: [1, 2, 3]
+`)
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Fatalf("expected error on line 5, got %v", err)
+	}
+}
+
+func TestParseConfigUnknownFields(t *testing.T) {
+	testValues := []struct {
+		config string
+		line   int
+	}{
+		{"address: 127.0.0.1:8080\nrequestProcesors:\n  - method: GET\n    processor: auth\n", 2},
+		{"address: 127.0.0.1:8080\nroutes:\n  - endpiont: hello\n    handler: test_config_route\n", 3},
+		{"address: 127.0.0.1:8080\nrequestProcessors:\n  - method: GET\n    procesor: auth\n", 4},
+		{"address: 127.0.0.1:8080\nresponseProcessors:\n  - type: html\n    handler: test_config_route\n    option: {}\n", 5},
+	}
+
+	for _, v := range testValues {
+		_, err := ParseConfig("test.yaml", []byte(v.config))
+
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("%q: expected ConfigError, got %v", v.config, err)
+		}
+
+		if configErr.File != "test.yaml" || configErr.Line != v.line {
+			t.Fatalf("%q: expected error on test.yaml:%d, got %s", v.config, v.line, configErr)
+		}
+	}
+
+	if _, err := ParseConfig("empty.yaml", nil); err != nil {
+		t.Fatalf("expected an empty config to parse, got %s", err)
+	}
+}
+
+// headerProcessor requires a header on every request, and then
+// sets it on every response it processes.
+type headerProcessor struct {
+	Header string `yaml:"header" den:"required"`
+}
+
+func (h *headerProcessor) ProcessRequest(req *http.Request) error {
+	if req.Header.Get(h.Header) == "" {
+		return errors.New("missing " + h.Header)
+	}
+
+	return nil
+}
+
+func (h *headerProcessor) ProcessResponse(resp *routing.ResponseInfo) error {
+	resp.Headers.Set(h.Header, "processed")
+	return nil
+}
+
+func init() {
+	RegisterIn(&RequestProcessors, "test_header", func(o headerProcessor) (routing.RequestProcessor, error) {
+		return &o, nil
+	})
+
+	RegisterIn(&ResponseProcessors, "test_header", func(o headerProcessor) (routing.ResponseProcessor, error) {
+		return &o, nil
+	})
+}
+
+func TestLoadConfigProcessors(t *testing.T) {
+	path := writeConfig(t, `address: 127.0.0.1:8080
+routes:
+  - endpoint: hello
+    handler: test_config_route
+    options:
+      body: Hello, world!
+requestProcessors:
+  - method: GET
+    processor: test_header
+    options:
+      header: X-Test
+responseProcessors:
+  - type: text
+    endpoint: hello
+    processor: test_header
+    options:
+      header: X-Processed
+`)
+
+	server, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected config to load, got: %s", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/hello/", nil)
+	req.Header.Set("X-Test", "yes")
+
+	server.Router.RouteRequest(w, req)
+
+	if w.Body.String() != "Hello, world!" {
+		t.Fatalf("expected Hello, world! as body, got %s", w.Body.String())
+	}
+
+	if w.Header().Get("X-Processed") != "processed" {
+		t.Fatalf("expected response processor to run, got headers %v", w.Header())
+	}
+}
+
+func TestLoadConfigProcessorErrors(t *testing.T) {
+	path := writeConfig(t, `address: 127.0.0.1:8080
+requestProcessors:
+  - method: GET
+    processor: does_not_exist
+  - method: GET
+    processor: test_header
+  - method: GET
+    processor: test_header
+    handler: test_config_route
+`)
+
+	_, err := LoadConfig(path)
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+
+	if !strings.Contains(errs[0].Error(), `unknown request processor "does_not_exist"`) {
+		t.Fatalf("expected unknown request processor error, got %s", errs[0])
+	}
+
+	if !strings.Contains(errs[1].Error(), `missing required option "header"`) {
+		t.Fatalf("expected missing option error, got %s", errs[1])
+	}
+}
+
+func TestLoadConfigAddressError(t *testing.T) {
+	path := writeConfig(t, `timeout: 5s
+production: true
+address: not an address
+`)
+
+	_, err := LoadConfig(path)
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("expected a single ConfigError, got %v", err)
+	}
+
+	if errs[0].Line != 3 {
+		t.Fatalf("expected the error on line 3, got line %d: %s", errs[0].Line, errs[0])
+	}
+}
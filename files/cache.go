@@ -0,0 +1,214 @@
+package files
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy describes the Cache-Control header that is sent along
+// with a file. The zero value sends no Cache-Control header at all, which
+// leaves it up to the client, though files are still revalidated through
+// their ETag and Last-Modified headers.
+type CachePolicy struct {
+	// MaxAge is how long the file can be cached for before
+	// it has to be revalidated.
+	MaxAge time.Duration `yaml:"maxAge"`
+	// NoCache makes clients revalidate the file every time it is used.
+	NoCache bool `yaml:"noCache"`
+	// NoStore stops clients from caching the file at all.
+	NoStore bool `yaml:"noStore"`
+	// Private stops shared caches (e.g., proxies) from caching the file.
+	Private bool `yaml:"private"`
+	// Immutable tells clients that the file never changes while it is
+	// cached, e.g., for files that have a hash in their name.
+	Immutable bool `yaml:"immutable"`
+}
+
+// String returns the policy as the value of a Cache-Control header.
+func (p CachePolicy) String() string {
+	var directives []string
+
+	if p.Private {
+		directives = append(directives, "private")
+	}
+
+	if p.NoStore {
+		directives = append(directives, "no-store")
+	}
+
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+
+	if p.MaxAge > 0 {
+		directives = append(directives, "max-age="+strconv.FormatInt(int64(p.MaxAge/time.Second), 10))
+	}
+
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// globPolicy is a cache policy for every file that matches a path glob.
+type globPolicy struct {
+	glob   string
+	policy CachePolicy
+}
+
+// SetCachePolicy sets the cache policy of every file that matches the given
+// pattern, which is either an extension (e.g., ".css"), or a glob of the
+// slash-separated path of the file from the handler's base path, as
+// path.Match takes it (e.g., "assets/*.js"). A file uses the policy of the
+// first glob it matches, then the policy of its extension, and then the
+// default policy, see SetDefaultCachePolicy. Setting the policy of a pattern
+// again replaces its policy.
+func (f *FileHandler) SetCachePolicy(pattern string, policy CachePolicy) error {
+	if isExtension(pattern) {
+		if f.extensionPolicies == nil {
+			f.extensionPolicies = make(map[string]CachePolicy)
+		}
+
+		f.extensionPolicies[strings.ToLower(pattern)] = policy
+
+		return nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("bad cache policy pattern %q: %w", pattern, err)
+	}
+
+	for i, g := range f.globPolicies {
+		if g.glob == pattern {
+			f.globPolicies[i].policy = policy
+			return nil
+		}
+	}
+
+	f.globPolicies = append(f.globPolicies, globPolicy{pattern, policy})
+
+	return nil
+}
+
+// SetDefaultCachePolicy sets the cache policy of every file
+// that doesn't match the pattern of any other policy.
+func (f *FileHandler) SetDefaultCachePolicy(policy CachePolicy) {
+	f.defaultPolicy = policy
+}
+
+// cachePolicy gets the cache policy of the file at the given
+// slash-separated path.
+func (f *FileHandler) cachePolicy(name string) CachePolicy {
+	for _, g := range f.globPolicies {
+		if ok, _ := path.Match(g.glob, name); ok {
+			return g.policy
+		}
+	}
+
+	if policy, ok := f.extensionPolicies[strings.ToLower(path.Ext(name))]; ok {
+		return policy
+	}
+
+	return f.defaultPolicy
+}
+
+// isExtension is whether the pattern of a cache policy is an
+// extension, rather than a glob.
+func isExtension(pattern string) bool {
+	return strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, `/*?[\`)
+}
+
+// etag creates the entity tag of the file at the given path from its size
+// and modification time, so that it can be created without reading the file.
+//
+// Some filesystems, like embed.FS, have no modification times, so the tag of
+// their files is a hash of their contents instead. Files without modification
+// times are assumed to never change, so their tags are only created once.
+func (f *FileHandler) etag(name string, info fs.FileInfo) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+
+	if tag, ok := f.contentTags.Load(name); ok {
+		return tag.(string)
+	}
+
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return ""
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ""
+	}
+
+	tag := fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+	f.contentTags.Store(name, tag)
+
+	return tag
+}
+
+// cacheHeaders creates the headers that let clients cache the file at the
+// given slash-separated path, and revalidate it later on.
+func (f *FileHandler) cacheHeaders(name string, info fs.FileInfo) http.Header {
+	headers := http.Header{}
+
+	if tag := f.etag(name, info); tag != "" {
+		headers.Set("ETag", tag)
+	}
+
+	if !info.ModTime().IsZero() {
+		headers.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	if policy := f.cachePolicy(name).String(); policy != "" {
+		headers.Set("Cache-Control", policy)
+	}
+
+	return headers
+}
+
+// notModified is whether the client already has the current version of
+// the file, going by the conditional headers of the request. If-None-Match
+// takes precedence over If-Modified-Since, as RFC 9110 requires.
+func notModified(req http.Header, headers http.Header, info fs.FileInfo) bool {
+	if match := req.Get("If-None-Match"); match != "" {
+		return etagMatches(match, headers.Get("ETag"))
+	}
+
+	since, err := http.ParseTime(req.Get("If-Modified-Since"))
+	if err != nil || info.ModTime().IsZero() {
+		return false
+	}
+
+	// Last-Modified only has a precision of seconds
+	return !info.ModTime().Truncate(time.Second).After(since)
+}
+
+// etagMatches is whether the list of entity tags in a conditional header
+// contains the given tag. Tags are compared weakly, ignoring the W/ prefix
+// of weak tags, as If-None-Match requires.
+func etagMatches(list string, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package files
+
+import (
+	"den/routing"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCachePolicy_String(t *testing.T) {
+	testValues := []struct {
+		policy   CachePolicy
+		expected string
+	}{
+		{CachePolicy{}, ""},
+		{CachePolicy{MaxAge: time.Hour}, "max-age=3600"},
+		{CachePolicy{MaxAge: 365 * 24 * time.Hour, Immutable: true}, "max-age=31536000, immutable"},
+		{CachePolicy{Private: true, NoCache: true}, "private, no-cache"},
+		{CachePolicy{NoStore: true}, "no-store"},
+	}
+
+	for _, v := range testValues {
+		if s := v.policy.String(); s != v.expected {
+			t.Fatalf("expected %q, got %q", v.expected, s)
+		}
+	}
+}
+
+func TestFileHandler_SetCachePolicy(t *testing.T) {
+	f := NewFileHandler(t.TempDir())
+	f.SetDefaultCachePolicy(CachePolicy{NoCache: true})
+
+	if err := f.SetCachePolicy(".CSS", CachePolicy{MaxAge: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetCachePolicy("assets/*", CachePolicy{MaxAge: time.Minute, Immutable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetCachePolicy("[", CachePolicy{}); err == nil {
+		t.Fatalf("expected a bad glob to be rejected")
+	}
+
+	testValues := []struct {
+		path     string
+		expected string
+	}{
+		{"style.css", "max-age=3600"},
+		{"theme/dark.Css", "max-age=3600"},
+		{"assets/style.css", "max-age=60, immutable"},
+		{"index.html", "no-cache"},
+	}
+
+	for _, v := range testValues {
+		if policy := f.cachePolicy(v.path).String(); policy != v.expected {
+			t.Fatalf("%s: expected %q, got %q", v.path, v.expected, policy)
+		}
+	}
+}
+
+func TestFileHandler_HandleRequestConditional(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := os.WriteFile(filepath.Join(dir, "test_file"), []byte("Hello, world!"), 0o644); err != nil {
+		t.Fatalf("error upon writing file: %s", err)
+	}
+
+	if err := os.Chtimes(filepath.Join(dir, "test_file"), modTime, modTime); err != nil {
+		t.Fatalf("error upon changing file times: %s", err)
+	}
+
+	router := routing.NewRouter()
+	router.RegisterRoute("test", NewFileHandler(dir))
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/test_file", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.RouteRequest(w, req)
+
+		return w
+	}
+
+	w := get(nil)
+	tag := w.Header().Get("ETag")
+
+	if w.Code != http.StatusOK || tag == "" {
+		t.Fatalf("expected http.StatusOK with an ETag, got code %d and ETag %q", w.Code, tag)
+	}
+
+	if lastModified := w.Header().Get("Last-Modified"); lastModified != "Tue, 01 Mar 2022 12:00:00 GMT" {
+		t.Fatalf("expected the modification time as Last-Modified, got %q", lastModified)
+	}
+
+	testValues := []struct {
+		headers  map[string]string
+		expected int
+	}{
+		{map[string]string{"If-None-Match": tag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", W/` + tag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": "Tue, 01 Mar 2022 12:00:00 GMT"}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": "Mon, 28 Feb 2022 12:00:00 GMT"}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": "not a date"}, http.StatusOK},
+		// If-None-Match takes precedence
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Tue, 01 Mar 2022 12:00:00 GMT"}, http.StatusOK},
+	}
+
+	for _, v := range testValues {
+		w := get(v.headers)
+
+		if w.Code != v.expected {
+			t.Fatalf("%v: expected %d, got %d", v.headers, v.expected, w.Code)
+		}
+
+		if w.Code == http.StatusNotModified {
+			if w.Body.Len() != 0 {
+				t.Fatalf("%v: expected no body with http.StatusNotModified, got %s", v.headers, w.Body.String())
+			}
+
+			if w.Header().Get("ETag") != tag {
+				t.Fatalf("%v: expected the ETag with http.StatusNotModified, got %q", v.headers, w.Header().Get("ETag"))
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package files
+
+import (
+	"bytes"
+	"den/routing"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultIndexFiles are the index files of a new FileHandler.
+var DefaultIndexFiles = []string{"index.html"}
+
+// SetIndexFiles sets the files that are sent in place of a directory, in
+// order of preference. If a directory has none of these, a listing of the
+// directory is sent instead, if listings are enabled.
+func (f *FileHandler) SetIndexFiles(names ...string) {
+	f.indexFiles = append([]string(nil), names...)
+}
+
+// SetListings sets whether a listing of a directory is sent when the
+// directory has no index file. If listings are disabled, requesting such
+// a directory is forbidden instead. Listings are disabled by default.
+func (f *FileHandler) SetListings(enabled bool) {
+	f.listings = enabled
+}
+
+// directoryResponse creates the response of a directory, at the given path
+// in the filesystem. A directory is only ever served under a path that
+// ends in a slash, so that relative links in it work, so any other request
+// for it is redirected there first.
+func (f *FileHandler) directoryResponse(req *routing.RequestInfo, dir fs.File, name string) *routing.ResponseInfo {
+	if u := req.URL(); u != nil && !strings.HasSuffix(u.Path, "/") {
+		dir.Close()
+		return trailingSlashRedirect(req)
+	}
+
+	for _, index := range f.indexFiles {
+		file, err := f.getFileAtPath(path.Join(name, index))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			dir.Close()
+			return f.errorResponse(req, err)
+		}
+
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			file.Close()
+			continue
+		}
+
+		dir.Close()
+		return f.fileResponse(req, file, info, path.Join(name, index))
+	}
+
+	if !f.listings {
+		dir.Close()
+		return f.errorResponse(req, newFileHandlerError(notAllowed, name, errors.New("directory listings are disabled")))
+	}
+
+	dir.Close()
+
+	return f.listingResponse(req, name)
+}
+
+// trailingSlashRedirect redirects the request to the same path, with a
+// slash at the end. The redirect is relative, so that it still works for
+// a handler that is mounted under a prefix.
+func trailingSlashRedirect(req *routing.RequestInfo) *routing.ResponseInfo {
+	u := req.URL()
+
+	location := "/"
+	if u.Path != "" {
+		location = lastSegment(u.EscapedPath()) + "/"
+	}
+
+	if u.RawQuery != "" {
+		location += "?" + u.RawQuery
+	}
+
+	resp := routing.CreateResponseInfo(http.StatusMovedPermanently, http.Header{"Location": {location}}, routing.None, req.RequestEndpoint(), nil)
+
+	return &resp
+}
+
+func lastSegment(p string) string {
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+// listingEntry is a single file in a directory listing.
+type listingEntry struct {
+	Name     string    `json:"name"`
+	Dir      bool      `json:"dir"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// listing is a listing of every file in a directory.
+type listing struct {
+	Path    string         `json:"path"`
+	Root    bool           `json:"-"`
+	Sort    string         `json:"-"`
+	Order   string         `json:"-"`
+	Entries []listingEntry `json:"entries"`
+}
+
+// listingSorts are the keys that a listing can be sorted by, through
+// the sort query parameter of the request.
+var listingSorts = map[string]func(a, b listingEntry) bool{
+	"name":     func(a, b listingEntry) bool { return a.Name < b.Name },
+	"size":     func(a, b listingEntry) bool { return a.Size < b.Size },
+	"modified": func(a, b listingEntry) bool { return a.Modified.Before(b.Modified) },
+}
+
+// listingResponse creates a listing of the directory, as JSON if the client
+// prefers it over HTML. The listing is sorted by the sort query parameter of
+// the request (name, size, or modified), in the order given by the order
+// query parameter (asc or desc), with directories first.
+func (f *FileHandler) listingResponse(req *routing.RequestInfo, name string) *routing.ResponseInfo {
+	entries, err := fs.ReadDir(f.fsys, name)
+	if err != nil {
+		return f.errorResponse(req, newFileHandlerError(accessError, name, err))
+	}
+
+	l := listing{
+		Path:  req.URL().Path,
+		Root:  len(req.Path) == 0,
+		Sort:  req.Query.Get("sort"),
+		Order: req.Query.Get("order"),
+	}
+
+	for _, entry := range entries {
+		if f.hideDotfiles && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// the file went away while listing
+			continue
+		}
+
+		e := listingEntry{
+			Name:     entry.Name(),
+			Dir:      entry.IsDir(),
+			Modified: info.ModTime().UTC(),
+		}
+
+		if !e.Dir {
+			e.Size = info.Size()
+		}
+
+		l.Entries = append(l.Entries, e)
+	}
+
+	less, ok := listingSorts[l.Sort]
+	if !ok {
+		l.Sort, less = "name", listingSorts["name"]
+	}
+
+	if l.Order != "desc" {
+		l.Order = "asc"
+	}
+
+	// fs.ReadDir sorts by name, so entries with
+	// the same key stay sorted by name
+	sort.SliceStable(l.Entries, func(i, j int) bool {
+		a, b := l.Entries[i], l.Entries[j]
+
+		if a.Dir != b.Dir {
+			return a.Dir
+		}
+
+		if l.Order == "desc" {
+			return less(b, a)
+		}
+
+		return less(a, b)
+	})
+
+	var buf bytes.Buffer
+	responseType := routing.Html
+
+	if prefersJSON(req.Headers().Get("Accept")) {
+		responseType = routing.Json
+		err = json.NewEncoder(&buf).Encode(l)
+	} else {
+		err = listingTemplate.Execute(&buf, l)
+	}
+
+	if err != nil {
+		return f.errorResponse(req, newFileHandlerError(accessError, name, err))
+	}
+
+	resp := routing.CreateResponseInfo(http.StatusOK, http.Header{}, responseType, req.RequestEndpoint(), &buf)
+
+	return &resp
+}
+
+// prefersJSON is whether the Accept header of a request prefers
+// application/json over text/html. Ties go to HTML.
+func prefersJSON(accept string) bool {
+	var jsonQuality, htmlQuality float64
+
+	for _, r := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(r))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "application/json":
+			jsonQuality = q
+		case "text/html":
+			htmlQuality = q
+		}
+	}
+
+	return jsonQuality > htmlQuality
+}
+
+// humanSize formats a size in bytes with binary prefixes, e.g., 1.5 KiB.
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+var listingTemplate = template.Must(template.New("listing").Funcs(template.FuncMap{
+	"humanSize": humanSize,
+	"sortLink": func(l listing, key string) string {
+		order := "asc"
+		if l.Sort == key && l.Order == "asc" {
+			order = "desc"
+		}
+
+		return "?sort=" + key + "&order=" + order
+	},
+	"href": func(e listingEntry) string {
+		href := (&url.URL{Path: e.Name}).String()
+		if e.Dir {
+			href += "/"
+		}
+
+		return href
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<thead>
+<tr><th><a href="{{sortLink . "name"}}">Name</a></th><th><a href="{{sortLink . "size"}}">Size</a></th><th><a href="{{sortLink . "modified"}}">Modified</a></th></tr>
+</thead>
+<tbody>
+{{- if not .Root}}
+<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{href .}}">{{.Name}}{{if .Dir}}/{{end}}</a></td><td>{{if .Dir}}-{{else}}{{humanSize .Size}}{{end}}</td><td>{{.Modified.Format "2006-01-02 15:04:05"}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
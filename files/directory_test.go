@@ -0,0 +1,205 @@
+package files
+
+import (
+	"den/routing"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// directoryTree creates a directory with:
+//
+//	a.txt          1 byte
+//	b.txt          3 bytes
+//	sub/           a directory
+//	site/index.html
+func directoryTree(t *testing.T) string {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"a.txt":           "a",
+		"b.txt":           "bbb",
+		"sub/c.txt":       "c",
+		"site/index.html": "<p>index</p>",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("error upon creating directory: %s", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("error upon writing file: %s", err)
+		}
+	}
+
+	return dir
+}
+
+func getPath(handler *FileHandler, rawUrl string, accept string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, rawUrl, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	return getRequest(handler, req)
+}
+
+// getRequest routes the request to the handler, under the endpoint test.
+func getRequest(handler *FileHandler, req *http.Request) *httptest.ResponseRecorder {
+	router := routing.NewRouter()
+	router.RegisterRoute("test", handler)
+
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, req)
+
+	return w
+}
+
+func TestFileHandler_HandleRequestDirectoryRedirect(t *testing.T) {
+	f := NewFileHandler(directoryTree(t))
+
+	testValues := []struct {
+		url      string
+		location string
+	}{
+		{"https://test.org/test/sub", "sub/"},
+		{"https://test.org/test/sub?sort=size", "sub/?sort=size"},
+		{"https://test.org/test", "test/"},
+	}
+
+	for _, v := range testValues {
+		w := getPath(f, v.url, "")
+
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != v.location {
+			t.Fatalf("%s: expected a redirect to %s, got code %d and %q", v.url, v.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestFileHandler_HandleRequestIndex(t *testing.T) {
+	f := NewFileHandler(directoryTree(t))
+
+	w := getPath(f, "https://test.org/test/site/", "")
+
+	if w.Code != http.StatusOK || w.Body.String() != "<p>index</p>" {
+		t.Fatalf("expected the index file, got code %d and body %s", w.Code, w.Body.String())
+	}
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || w.Header().Get("ETag") == "" {
+		t.Fatalf("expected the index file to be sent as a file, got %v", w.Header())
+	}
+
+	f.SetIndexFiles("c.txt")
+
+	if w := getPath(f, "https://test.org/test/sub/", ""); w.Body.String() != "c" {
+		t.Fatalf("expected c.txt as the index file, got %s", w.Body.String())
+	}
+}
+
+func TestFileHandler_HandleRequestListing(t *testing.T) {
+	f := NewFileHandler(directoryTree(t))
+	f.SetListings(true)
+
+	w := getPath(f, "https://test.org/test/", "")
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected an HTML listing, got code %d and %v", w.Code, w.Header())
+	}
+
+	body := w.Body.String()
+	for _, link := range []string{`href="a.txt"`, `href="sub/"`, `href="site/"`, "1 B", "3 B"} {
+		if !strings.Contains(body, link) {
+			t.Fatalf("expected %s in the listing:\n%s", link, body)
+		}
+	}
+
+	if strings.Contains(body, `href="../"`) {
+		t.Fatalf("expected no parent directory at the base path:\n%s", body)
+	}
+
+	if w := getPath(f, "https://test.org/test/sub/", ""); !strings.Contains(w.Body.String(), `href="../"`) {
+		t.Fatalf("expected a parent directory in a subdirectory:\n%s", w.Body.String())
+	}
+
+	testValues := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"site", "sub", "a.txt", "b.txt"}},
+		{"?order=desc", []string{"sub", "site", "b.txt", "a.txt"}},
+		{"?sort=size&order=desc", []string{"site", "sub", "b.txt", "a.txt"}},
+		{"?sort=bogus", []string{"site", "sub", "a.txt", "b.txt"}},
+	}
+
+	for _, v := range testValues {
+		w := getPath(f, "https://test.org/test/"+v.query, "application/json, text/html;q=0.9")
+
+		var l struct {
+			Path    string `json:"path"`
+			Entries []struct {
+				Name string `json:"name"`
+				Dir  bool   `json:"dir"`
+				Size int64  `json:"size"`
+			} `json:"entries"`
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &l); err != nil {
+			t.Fatalf("%s: expected a JSON listing, got %s: %s", v.query, w.Body.String(), err)
+		}
+
+		var names []string
+		for _, e := range l.Entries {
+			names = append(names, e.Name)
+		}
+
+		if strings.Join(names, ",") != strings.Join(v.expected, ",") {
+			t.Fatalf("%s: expected %v, got %v", v.query, v.expected, names)
+		}
+
+		if l.Path != "/test/" {
+			t.Fatalf("%s: expected /test/ as the path, got %s", v.query, l.Path)
+		}
+	}
+}
+
+func TestFileHandler_HandleRequestListingDisabled(t *testing.T) {
+	f := NewFileHandler(directoryTree(t))
+
+	// listings are disabled by default
+	if w := getPath(f, "https://test.org/test/sub/", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected http.StatusForbidden by default, got %d", w.Code)
+	}
+
+	f.SetListings(true)
+	f.SetListings(false)
+
+	if w := getPath(f, "https://test.org/test/sub/", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected http.StatusForbidden, got %d", w.Code)
+	}
+
+	// index files are still sent
+	if w := getPath(f, "https://test.org/test/site/", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected http.StatusOK, got %d", w.Code)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	testValues := map[int64]string{
+		0:                  "0 B",
+		1023:               "1023 B",
+		1536:               "1.5 KiB",
+		5 * 1024 * 1024:    "5.0 MiB",
+		1024 * 1024 * 1024: "1.0 GiB",
+	}
+
+	for size, expected := range testValues {
+		if s := humanSize(size); s != expected {
+			t.Fatalf("%d: expected %s, got %s", size, expected, s)
+		}
+	}
+}
@@ -4,90 +4,302 @@ import (
 	"bytes"
 	"den/routing"
 	"errors"
+	"io"
 	"io/fs"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"path"
 	"strings"
+	"sync"
 )
 
 // FileHandler transmits a single file over, from a specific directory on
-// the current host's filesystem. If the handler fails to grab a file,
-// it will return a ResponseInfo of type text, with the raw error
-// in question. Otherwise, it will return the data file's reader.
+// the current host's filesystem, or from any other fs.FS (see
+// NewFileHandlerFS). If the handler fails to grab a file, it will return
+// a ResponseInfo of type text, with the raw error in question. Otherwise,
+// it will return the data file's reader.
+//
+// Every file is sent with an ETag and a Last-Modified header, created from
+// its size and modification time, so that clients can revalidate their
+// cached copy through If-None-Match or If-Modified-Since. A file that hasn't
+// changed is responded to with 304 Not Modified, and a response of type None.
+// How long clients may cache files for is set through SetCachePolicy.
+//
+// Parts of a file can be requested through the Range header, which are sent
+// with 206 Partial Content, as multipart/byteranges if there is more than one
+// range. Ranges that don't overlap the file are responded to with 416 Range
+// Not Satisfiable. If-Range is respected, so that a client resuming a file
+// that has changed since gets the whole file again. Ranges are only supported
+// for files that implement io.ReaderAt, as *os.File and embedded files do.
+//
+// A directory is sent as its index file (see SetIndexFiles). Otherwise,
+// requesting it is forbidden, unless listings of its files in HTML or JSON
+// are enabled (see SetListings).
+//
+// Files are only sent for GET and HEAD requests, and any other method is
+// responded to with 405 Method Not Allowed.
 type FileHandler struct {
+	// basePath is the directory that fsys is rooted at, if
+	// the handler was created through NewFileHandler.
 	basePath string
+	fsys     fs.FS
+
+	indexFiles   []string
+	listings     bool
+	hideDotfiles bool
+
+	defaultPolicy     CachePolicy
+	extensionPolicies map[string]CachePolicy
+	globPolicies      []globPolicy
+
+	// contentTags are the entity tags of files without a
+	// modification time, by their path, see etag.
+	contentTags sync.Map
 }
 
+// NewFileHandler creates a FileHandler for the directory at the given path
+// on the current host's filesystem. Symlinks in the directory are followed,
+// as long as they don't lead outside of it.
 func NewFileHandler(path string) *FileHandler {
-	handler := new(FileHandler)
 	if !strings.HasSuffix(path, "/") {
 		path = path + "/"
 	}
 
+	handler := NewFileHandlerFS(newRootFS(path))
 	handler.basePath = path
 
 	return handler
 }
 
-func (f *FileHandler) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
-	path := filepath.FromSlash(strings.Join(req.Path, "/"))
+// NewFileHandlerFS creates a FileHandler for the given filesystem, e.g., an
+// embed.FS, or a zip archive through zip.Reader. Every request is a path in
+// the filesystem, and paths outside of it are rejected the same way as paths
+// outside the directory of NewFileHandler.
+func NewFileHandlerFS(fsys fs.FS) *FileHandler {
+	handler := new(FileHandler)
+	handler.fsys = fsys
+	handler.indexFiles = DefaultIndexFiles
 
-	if req.Method() != http.MethodGet {
-		err := newFileHandlerError(invalidMethod, path, nil)
-		text := bytes.NewBufferString(err.Error())
+	return handler
+}
 
-		resp := routing.CreateResponseInfo(http.StatusBadRequest, http.Header{}, routing.Text, req.RequestEndpoint(), text)
+func (f *FileHandler) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	// HEAD gets the same headers as GET, and net/http drops the body
+	if req.Method() != http.MethodGet && req.Method() != http.MethodHead {
+		return f.errorResponse(req, newFileHandlerError(invalidMethod, strings.Join(req.Path, "/"), nil)), nil
+	}
 
-		return &resp, nil
+	name, err := requestPath(req.Path)
+	if err != nil {
+		return f.errorResponse(req, err), nil
 	}
 
-	file, err := f.getFileAtPath(path)
+	file, err := f.getFileAtPath(name)
+	if err != nil {
+		return f.errorResponse(req, err), nil
+	}
 
+	name, _ = cleanPath(name)
+
+	info, err := file.Stat()
 	if err != nil {
-		e := err.(fileHandlerError)
-		var code int
+		file.Close()
+		return f.errorResponse(req, newFileHandlerError(accessError, name, err)), nil
+	}
 
-		switch e.code {
-		case notAllowed:
-			code = http.StatusForbidden
-		case accessError:
-			switch {
-			case errors.Is(err, fs.ErrNotExist):
-				code = http.StatusNotFound
-			case errors.Is(err, fs.ErrPermission):
-				code = http.StatusForbidden
-			default:
-				// something really odd happened, so
-				// it might be a server-side thing
-				code = http.StatusServiceUnavailable
-			}
+	if info.IsDir() {
+		return f.directoryResponse(req, file, name), nil
+	}
+
+	return f.fileResponse(req, file, info, name), nil
+}
+
+// fileResponse creates the response of a file, at the given path in the
+// filesystem, which takes the conditional and range headers of the request
+// into account.
+func (f *FileHandler) fileResponse(req *routing.RequestInfo, file fs.File, info fs.FileInfo, name string) *routing.ResponseInfo {
+	headers := f.cacheHeaders(name, info)
+
+	if notModified(req.Headers(), headers, info) {
+		file.Close()
+
+		resp := routing.CreateResponseInfo(http.StatusNotModified, headers, routing.None, req.RequestEndpoint(), nil)
+
+		return &resp
+	}
+
+	if _, ok := file.(io.ReaderAt); ok {
+		headers.Set("Accept-Ranges", "bytes")
+
+		if resp := f.rangeResponse(req, file, info, name, headers); resp != nil {
+			return resp
 		}
+	}
+
+	resp := routing.CreateResponseInfo(http.StatusOK, headers, routing.Data, req.RequestEndpoint(), newFileBody(file, info, name))
+
+	return &resp
+}
+
+// fileBody is a body that knows its name, and how much of it is left to
+// read, so that the router can work out its Content-Type and Content-Length
+// (see routing.ResponseInfo.Finalize) from those, rather than from what the
+// file itself says.
+type fileBody struct {
+	io.Reader
+	io.Closer
+
+	name      string
+	remaining int64
+}
+
+// newFileBody creates the body of a whole file, as long as its size is
+// known. Files from an fs.FS don't have to know their name, or be able to
+// seek, and the name of an *os.File is that of whatever a symlink led to,
+// so the file is always sent under the name it was requested by.
+func newFileBody(file fs.File, info fs.FileInfo, name string) io.Reader {
+	if !info.Mode().IsRegular() {
+		return file
+	}
+
+	return &fileBody{file, file, name, info.Size()}
+}
+
+func (b *fileBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.remaining -= int64(n)
 
-		text := bytes.NewBufferString(e.Error())
+	return n, err
+}
+
+func (b *fileBody) Name() string {
+	return b.name
+}
+
+func (b *fileBody) Len() int {
+	return int(b.remaining)
+}
 
-		resp := routing.CreateResponseInfo(code, http.Header{}, routing.Text, req.RequestEndpoint(), text)
+// errorResponse creates the text response of an error from getting a file.
+func (f *FileHandler) errorResponse(req *routing.RequestInfo, err error) *routing.ResponseInfo {
+	e := err.(fileHandlerError)
+	headers := http.Header{}
+	var code int
 
-		return &resp, nil
+	switch e.code {
+	case invalidMethod:
+		code = http.StatusMethodNotAllowed
+		headers.Set("Allow", "GET, HEAD")
+	case notAllowed:
+		code = http.StatusForbidden
+	case accessError:
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			code = http.StatusNotFound
+		case errors.Is(err, fs.ErrPermission):
+			code = http.StatusForbidden
+		default:
+			// something really odd happened, so
+			// it might be a server-side thing
+			code = http.StatusServiceUnavailable
+		}
 	}
 
-	resp := routing.CreateResponseInfo(http.StatusOK, http.Header{}, routing.Data, req.RequestEndpoint(), file)
+	text := bytes.NewBufferString(e.Error())
+
+	resp := routing.CreateResponseInfo(code, headers, routing.Text, req.RequestEndpoint(), text)
 
-	return &resp, nil
+	return &resp
 }
 
-func (f *FileHandler) getFileAtPath(path string) (*os.File, error) {
-	// only absolute file paths allowed here, buddy
-	if !filepath.IsAbs(path) {
-		return nil, newFileHandlerError(notAllowed, path, errors.New("relative file pathing not allowed"))
+// SetHideDotfiles sets whether files whose name starts with a dot (e.g.,
+// .git or .env), and anything inside of them, are hidden. Hidden files
+// are left out of listings, and requesting them is responded to as if
+// they don't exist, as is requesting them through a symlink. Dotfiles are
+// not hidden by default.
+func (f *FileHandler) SetHideDotfiles(hide bool) {
+	f.hideDotfiles = hide
+
+	// only the host's filesystem has symlinks to
+	// look through for dotfiles along the way
+	if root, ok := f.fsys.(*rootFS); ok {
+		root.hideDotfiles = hide
 	}
+}
 
-	fullPath := filepath.Join(f.basePath, path)
+// getFileAtPath opens the file at the given slash-separated path, see cleanPath.
+func (f *FileHandler) getFileAtPath(path string) (fs.File, error) {
+	name, err := cleanPath(path)
+	if err != nil {
+		return nil, err
+	}
 
-	file, err := os.Open(fullPath)
+	if f.hideDotfiles && hasDotfile(name) {
+		return nil, newFileHandlerError(accessError, path, fs.ErrNotExist)
+	}
+
+	file, err := f.fsys.Open(name)
 	if err != nil {
+		if errors.Is(err, errOutsideBase) {
+			return nil, newFileHandlerError(notAllowed, path, errOutsideBase)
+		}
+
 		return nil, newFileHandlerError(accessError, path, err)
 	}
 
 	return file, nil
 }
+
+// requestPath unescapes the segments of a request's path, which the router
+// leaves escaped, into a slash-separated path. Segments that don't unescape
+// into a single file name, e.g., as they have an encoded separator in them,
+// are rejected, so that they can't be used to sneak in a path of their own.
+func requestPath(segments []string) (string, error) {
+	unescaped := make([]string, len(segments))
+
+	for i, segment := range segments {
+		s, err := url.PathUnescape(segment)
+		if err != nil || strings.ContainsAny(s, "/\\\x00") {
+			return "", newFileHandlerError(notAllowed, strings.Join(segments, "/"), errors.New("invalid path segment"))
+		}
+
+		unescaped[i] = s
+	}
+
+	return strings.Join(unescaped, "/"), nil
+}
+
+// cleanPath cleans the given slash-separated path from a request into the
+// path of a file in the filesystem, as fs.ValidPath requires.
+func cleanPath(name string) (string, error) {
+	// nothing outside the base path allowed here, buddy
+	if rel := path.Clean(name); rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", newFileHandlerError(notAllowed, name, errOutsideBase)
+	}
+
+	clean := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if clean == "" {
+		clean = "."
+	}
+
+	if !fs.ValidPath(clean) {
+		return "", newFileHandlerError(notAllowed, name, errors.New("invalid path"))
+	}
+
+	return clean, nil
+}
+
+// hasDotfile is whether any file along the cleaned path is a dotfile.
+func hasDotfile(name string) bool {
+	if name == "." {
+		return false
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return true
+		}
+	}
+
+	return false
+}
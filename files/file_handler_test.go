@@ -1,14 +1,13 @@
 package files
 
 import (
-	"bytes"
 	"den/routing"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
 	"testing"
 )
 
@@ -34,27 +33,6 @@ func TestFileHandler_getFileAtPathRelativePathing(t *testing.T) {
 	}
 }
 
-// TODO: this should be some kind of common testing thing
-type dummyWriter struct {
-	data    bytes.Buffer
-	headers http.Header
-	code    int
-	final   []byte
-}
-
-func (d *dummyWriter) Write(i []byte) (int, error) {
-	return d.data.Write(i)
-}
-
-func (d *dummyWriter) WriteHeader(statusCode int) {
-	d.code = statusCode
-	d.final = d.data.Bytes()
-}
-
-func (d *dummyWriter) Header() http.Header {
-	return d.headers
-}
-
 func TestFileHandler_HandleRequest(t *testing.T) {
 	dir := t.TempDir()
 	testFile := filepath.Join(dir, "test_file")
@@ -81,20 +59,16 @@ func TestFileHandler_HandleRequest(t *testing.T) {
 		URL:    testUrl,
 	}
 
-	w := new(dummyWriter)
+	w := httptest.NewRecorder()
 
 	router.RouteRequest(w, req)
 
-	var s strings.Builder
-
-	s.Write(w.final)
-
-	if w.code != http.StatusOK || len(w.final) == 0 {
-		t.Fatalf("expected http.StatusOK with filled buffer, got code %d and body %s", w.code, s.String())
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Fatalf("expected http.StatusOK with filled buffer, got code %d and body %s", w.Code, w.Body.String())
 	}
 
-	if s.String() != "Hello, world!" {
-		t.Fatalf("expected Hello, world! as body, got %s", s.String())
+	if w.Body.String() != "Hello, world!" {
+		t.Fatalf("expected Hello, world! as body, got %s", w.Body.String())
 	}
 }
 
@@ -126,15 +100,57 @@ func TestFileHandler_HandleRequestWithRelativePathing(t *testing.T) {
 		URL:    testUrl,
 	}
 
-	w := new(dummyWriter)
+	w := httptest.NewRecorder()
 
 	router.RouteRequest(w, req)
 
-	var s strings.Builder
+	if w.Code != http.StatusForbidden || w.Body.Len() == 0 {
+		t.Fatalf("expected http.StatusForbidden with filled buffer, got code %d and body %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFileHandler_HandleRequestMethods(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "test_file.txt"), []byte("Hello, world!"), 0o644); err != nil {
+		t.Fatalf("error upon writing file: %s", err)
+	}
+
+	router := routing.NewRouter()
+	router.RegisterRoute("test", NewFileHandler(dir))
+
+	server := httptest.NewServer(http.HandlerFunc(router.RouteRequest))
+	defer server.Close()
+
+	get, err := http.Get(server.URL + "/test/test_file.txt")
+	if err != nil {
+		t.Fatalf("error upon GET: %s", err)
+	}
+	get.Body.Close()
+
+	head, err := http.Head(server.URL + "/test/test_file.txt")
+	if err != nil {
+		t.Fatalf("error upon HEAD: %s", err)
+	}
+	head.Body.Close()
+
+	if head.StatusCode != http.StatusOK || head.ContentLength != 13 {
+		t.Fatalf("expected http.StatusOK with the length of the file, got code %d and length %d", head.StatusCode, head.ContentLength)
+	}
+
+	for _, header := range []string{"Accept-Ranges", "ETag", "Last-Modified", "Content-Type"} {
+		if head.Header.Get(header) == "" || head.Header.Get(header) != get.Header.Get(header) {
+			t.Fatalf("expected %s of HEAD to be that of GET, got %q", header, head.Header.Get(header))
+		}
+	}
 
-	s.Write(w.final)
+	post, err := http.Post(server.URL+"/test/test_file.txt", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("error upon POST: %s", err)
+	}
+	post.Body.Close()
 
-	if w.code != http.StatusForbidden || len(w.final) == 0 {
-		t.Fatalf("expected http.StatusForbidden with filled buffer, got code %d and body %s", w.code, s.String())
+	if post.StatusCode != http.StatusMethodNotAllowed || post.Header.Get("Allow") != "GET, HEAD" {
+		t.Fatalf("expected http.StatusMethodNotAllowed with GET and HEAD allowed, got code %d and %v", post.StatusCode, post.Header)
 	}
 }
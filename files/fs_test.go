@@ -0,0 +1,187 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"den/routing"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestNewFileHandlerFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<p>index</p>"), ModTime: time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)},
+		"assets/app.js": {Data: []byte("console.log('hi')")},
+		"assets/other":  {Data: []byte("console.log('no')")},
+	}
+
+	f := NewFileHandlerFS(fsys)
+
+	w := getPath(f, "https://test.org/test/assets/app.js", "")
+
+	if w.Code != http.StatusOK || w.Body.String() != "console.log('hi')" {
+		t.Fatalf("expected http.StatusOK with app.js, got code %d and body %s", w.Code, w.Body.String())
+	}
+
+	if !strings.Contains(w.Header().Get("Content-Type"), "javascript") || w.Header().Get("Content-Length") != "17" {
+		t.Fatalf("expected the content type and length of app.js, got %v", w.Header())
+	}
+
+	// app.js has no modification time, so it's tagged by its contents,
+	// which tells it apart from another file of the same size
+	tag := w.Header().Get("ETag")
+	if tag == "" || w.Header().Get("Last-Modified") != "" {
+		t.Fatalf("expected an ETag without Last-Modified, got %v", w.Header())
+	}
+
+	if other := getPath(f, "https://test.org/test/assets/other", ""); other.Header().Get("ETag") == tag {
+		t.Fatalf("expected files with different contents to have different ETags, both got %s", tag)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/assets/app.js", nil)
+	req.Header.Set("If-None-Match", tag)
+
+	if w := getRequest(f, req); w.Code != http.StatusNotModified {
+		t.Fatalf("expected http.StatusNotModified, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://test.org/test/assets/app.js", nil)
+	req.Header.Set("Range", "bytes=0-6")
+
+	if w := getRequest(f, req); w.Code != http.StatusPartialContent || w.Body.String() != "console" {
+		t.Fatalf("expected http.StatusPartialContent with console, got code %d and body %s", w.Code, w.Body.String())
+	}
+
+	testValues := []struct {
+		url      string
+		expected int
+		body     string
+	}{
+		{"https://test.org/test/", http.StatusOK, "<p>index</p>"},
+		{"https://test.org/test/assets", http.StatusMovedPermanently, ""},
+		{"https://test.org/test/missing", http.StatusNotFound, ""},
+		{"https://test.org/test/../../index.html", http.StatusForbidden, ""},
+		{"https://test.org/test/assets/../../index.html", http.StatusForbidden, ""},
+		{"https://test.org/test/assets/../index.html", http.StatusOK, "<p>index</p>"},
+	}
+
+	for _, v := range testValues {
+		w := getPath(f, v.url, "")
+
+		if w.Code != v.expected {
+			t.Fatalf("%s: expected %d, got %d", v.url, v.expected, w.Code)
+		}
+
+		if v.body != "" && w.Body.String() != v.body {
+			t.Fatalf("%s: expected %s as body, got %s", v.url, v.body, w.Body.String())
+		}
+	}
+}
+
+func TestNewFileHandlerFSZip(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for name, content := range map[string]string{
+		"hello.txt":    "Hello, world!",
+		"docs/a.txt":   "a",
+		"docs/b/c.txt": "c",
+	} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("error upon creating %s: %s", name, err)
+		}
+
+		w.Write([]byte(content))
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatalf("error upon closing archive: %s", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("error upon reading archive: %s", err)
+	}
+
+	f := NewFileHandlerFS(reader)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/hello.txt", nil)
+	req.Header.Set("Range", "bytes=0-4")
+
+	// files in a zip archive can't be read at an offset,
+	// so the whole file is sent instead
+	w := getRequest(f, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "Hello, world!" {
+		t.Fatalf("expected http.StatusOK with the whole file, got code %d and body %s", w.Code, w.Body.String())
+	}
+
+	if w.Header().Get("Accept-Ranges") != "" {
+		t.Fatalf("expected no ranges to be advertised, got %q", w.Header().Get("Accept-Ranges"))
+	}
+
+	f.SetListings(true)
+	w = getPath(f, "https://test.org/test/docs/", "")
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="a.txt"`) || !strings.Contains(w.Body.String(), `href="b/"`) {
+		t.Fatalf("expected a listing of docs, got code %d and body %s", w.Code, w.Body.String())
+	}
+}
+
+// replaceBody is a response processor that replaces the body of a response.
+type replaceBody struct{}
+
+func (replaceBody) ProcessResponse(resp *routing.ResponseInfo) error {
+	if closer, ok := resp.Body.(io.Closer); ok {
+		closer.Close()
+	}
+
+	resp.Body = strings.NewReader("replaced")
+	return nil
+}
+
+// the Content-Length of a file is left for the router to work out,
+// so that it still matches if the body is replaced along the way
+func TestNewFileHandlerFSReplacedBody(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	w, _ := archive.Create("hello.txt")
+	w.Write([]byte("Hello, world!"))
+	archive.Close()
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("error upon reading archive: %s", err)
+	}
+
+	for name, fsys := range map[string]fs.FS{
+		"map": fstest.MapFS{"hello.txt": {Data: []byte("Hello, world!")}},
+		"zip": reader,
+	} {
+		f := NewFileHandlerFS(fsys)
+
+		if w := getPath(f, "https://test.org/test/hello.txt", ""); w.Header().Get("Content-Length") != "13" {
+			t.Fatalf("%s: expected 13 as Content-Length, got %v", name, w.Header())
+		}
+
+		router := routing.NewRouter()
+		router.RegisterRoute("test", f)
+		router.RegisterResponseProcessor(routing.Data, "test", replaceBody{})
+
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/hello.txt", nil)
+		w := httptest.NewRecorder()
+		router.RouteRequest(w, req)
+
+		if w.Body.String() != "replaced" || w.Header().Get("Content-Length") != "8" {
+			t.Fatalf("%s: expected replaced with a Content-Length of 8, got %s and %v", name, w.Body.String(), w.Header())
+		}
+	}
+}
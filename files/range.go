@@ -0,0 +1,252 @@
+package files
+
+import (
+	"den/routing"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errMalformedRange is returned for a Range header that can't be parsed,
+	// in which case the header is ignored, and the whole file is sent.
+	errMalformedRange = errors.New("malformed range")
+	// errUnsatisfiableRange is returned for a Range header where none of
+	// the ranges overlap the file, which is responded to with 416.
+	errUnsatisfiableRange = errors.New("unsatisfiable range")
+)
+
+// byteRange is a range of bytes in a file, which starts at start,
+// and is length bytes long.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange gets the value of the Content-Range header
+// of this range, in a file of the given size.
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange parses the value of a Range header, as given in RFC 9110,
+// for a file of the given size. Ranges that don't overlap the file are
+// dropped, and ranges that go past the end of the file are cut short.
+func parseRange(spec string, size int64) ([]byteRange, error) {
+	const prefix = "bytes="
+
+	if len(spec) < len(prefix) || !strings.EqualFold(spec[:len(prefix)], prefix) {
+		return nil, errMalformedRange
+	}
+
+	var ranges []byteRange
+
+	for _, part := range strings.Split(spec[len(prefix):], ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, errMalformedRange
+		}
+
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		if first == "" {
+			// a suffix range, of the last bytes of the file
+			length, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || length < 0 {
+				return nil, errMalformedRange
+			}
+
+			if length == 0 || size == 0 {
+				continue
+			}
+
+			if length > size {
+				length = size
+			}
+
+			ranges = append(ranges, byteRange{size - length, length})
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, errMalformedRange
+		}
+
+		end := size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, errMalformedRange
+			}
+
+			if end >= size {
+				end = size - 1
+			}
+		}
+
+		if start >= size {
+			continue
+		}
+
+		ranges = append(ranges, byteRange{start, end - start + 1})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+
+	return ranges, nil
+}
+
+// rangeApplies is whether the Range header of a request applies to the
+// current version of the file, going by the If-Range header of the
+// request. A file that changed since the client got the rest of it has
+// to be sent again as a whole.
+func rangeApplies(req http.Header, headers http.Header) bool {
+	condition := req.Get("If-Range")
+	if condition == "" {
+		return true
+	}
+
+	// If-Range is either an entity tag, which is compared strongly,
+	// or a date, which has to be the exact modification time
+	if strings.HasPrefix(condition, `"`) {
+		return condition == headers.Get("ETag")
+	}
+
+	if strings.HasPrefix(condition, "W/") {
+		return false
+	}
+
+	since, err := http.ParseTime(condition)
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(headers.Get("Last-Modified"))
+
+	return err == nil && since.Equal(modified)
+}
+
+// rangeResponse creates the response to a request for part of the file at
+// the given path, if the request has a Range header that applies to it.
+// Otherwise, nil is returned, and the whole file should be sent instead.
+// The file must implement io.ReaderAt.
+func (f *FileHandler) rangeResponse(req *routing.RequestInfo, file fs.File, info fs.FileInfo, name string, headers http.Header) *routing.ResponseInfo {
+	spec := req.Headers().Get("Range")
+	if spec == "" || !rangeApplies(req.Headers(), headers) {
+		return nil
+	}
+
+	size := info.Size()
+	ranges, err := parseRange(spec, size)
+
+	switch {
+	case errors.Is(err, errUnsatisfiableRange):
+		file.Close()
+		headers.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+
+		resp := routing.CreateResponseInfo(http.StatusRequestedRangeNotSatisfiable, headers, routing.None, req.RequestEndpoint(), nil)
+
+		return &resp
+	case err != nil:
+		return nil
+	}
+
+	var total int64
+	for _, r := range ranges {
+		total += r.length
+	}
+
+	// asking for more than the whole file, through overlapping
+	// ranges, gets the whole file instead
+	if total > size {
+		return nil
+	}
+
+	readerAt := file.(io.ReaderAt)
+	contentType := fileContentType(readerAt, name)
+
+	if len(ranges) == 1 {
+		headers.Set("Content-Range", ranges[0].contentRange(size))
+		headers.Set("Content-Type", contentType)
+
+		body := sectionFile{io.NewSectionReader(readerAt, ranges[0].start, ranges[0].length), file}
+		resp := routing.CreateResponseInfo(http.StatusPartialContent, headers, routing.Data, req.RequestEndpoint(), body)
+
+		return &resp
+	}
+
+	body := multipartRanges(readerAt, contentType, size, ranges, headers)
+
+	resp := routing.CreateResponseInfo(http.StatusPartialContent, headers, routing.Data, req.RequestEndpoint(), body)
+
+	return &resp
+}
+
+// multipartRanges creates the multipart/byteranges body of a response with
+// more than one range, and sets its Content-Type in the given headers. The
+// body is read straight from the file, so it keeps track of its length.
+func multipartRanges(file io.ReaderAt, contentType string, size int64, ranges []byteRange, headers http.Header) io.Reader {
+	// the writer is only used to come up with a random boundary
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	headers.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+
+	var length int64
+	parts := make([]io.Reader, 0, len(ranges)*2+1)
+
+	for i, r := range ranges {
+		var part strings.Builder
+
+		if i > 0 {
+			part.WriteString("\r\n")
+		}
+
+		part.WriteString("--" + boundary + "\r\n")
+		part.WriteString("Content-Type: " + contentType + "\r\n")
+		part.WriteString("Content-Range: " + r.contentRange(size) + "\r\n\r\n")
+
+		parts = append(parts, strings.NewReader(part.String()), io.NewSectionReader(file, r.start, r.length))
+		length += int64(part.Len()) + r.length
+	}
+
+	end := "\r\n--" + boundary + "--\r\n"
+	parts = append(parts, strings.NewReader(end))
+	length += int64(len(end))
+
+	return &fileBody{Reader: io.MultiReader(parts...), Closer: file.(io.Closer), remaining: length}
+}
+
+// fileContentType gets the content type of the file at the given path from
+// its extension, or otherwise by sniffing the start of it, in the same way
+// that the router does for Data responses.
+func fileContentType(file io.ReaderAt, name string) string {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+
+	buf := make([]byte, 512)
+	n, _ := file.ReadAt(buf, 0)
+
+	return http.DetectContentType(buf[:n])
+}
+
+// sectionFile is part of a file, which closes the file once it has been
+// sent. It is still an io.Seeker, so that the router knows its length.
+type sectionFile struct {
+	*io.SectionReader
+	io.Closer
+}
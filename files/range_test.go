@@ -0,0 +1,179 @@
+package files
+
+import (
+	"den/routing"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	testValues := []struct {
+		spec     string
+		expected []byteRange
+		err      error
+	}{
+		{"bytes=0-4", []byteRange{{0, 5}}, nil},
+		{"bytes=5-", []byteRange{{5, 8}}, nil},
+		{"bytes=-3", []byteRange{{10, 3}}, nil},
+		{"bytes=-100", []byteRange{{0, 13}}, nil},
+		{"bytes=10-100", []byteRange{{10, 3}}, nil},
+		{"Bytes=0-0, 2-3", []byteRange{{0, 1}, {2, 2}}, nil},
+		{"bytes=0-1, 50-60", []byteRange{{0, 2}}, nil},
+		{"bytes=13-", nil, errUnsatisfiableRange},
+		{"bytes=-0", nil, errUnsatisfiableRange},
+		{"bytes=4-2", nil, errMalformedRange},
+		{"bytes=a-b", nil, errMalformedRange},
+		{"bytes=-", nil, errMalformedRange},
+		{"lines=0-4", nil, errMalformedRange},
+	}
+
+	for _, v := range testValues {
+		ranges, err := parseRange(v.spec, 13)
+
+		if !errors.Is(err, v.err) {
+			t.Fatalf("%s: expected error %v, got %v", v.spec, v.err, err)
+		}
+
+		if !reflect.DeepEqual(ranges, v.expected) {
+			t.Fatalf("%s: expected %v, got %v", v.spec, v.expected, ranges)
+		}
+	}
+}
+
+func TestFileHandler_HandleRequestRange(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "test_file.txt"), []byte("Hello, world!"), 0o644); err != nil {
+		t.Fatalf("error upon writing file: %s", err)
+	}
+
+	router := routing.NewRouter()
+	router.RegisterRoute("test", NewFileHandler(dir))
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/test_file.txt", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		router.RouteRequest(w, req)
+
+		return w
+	}
+
+	full := get(nil)
+	if full.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("expected byte ranges to be advertised, got %q", full.Header().Get("Accept-Ranges"))
+	}
+
+	w := get(map[string]string{"Range": "bytes=7-11"})
+
+	if w.Code != http.StatusPartialContent || w.Body.String() != "world" {
+		t.Fatalf("expected http.StatusPartialContent with world, got code %d and body %s", w.Code, w.Body.String())
+	}
+
+	if w.Header().Get("Content-Range") != "bytes 7-11/13" || w.Header().Get("Content-Length") != "5" {
+		t.Fatalf("expected the range and its length, got %v", w.Header())
+	}
+
+	if w.Header().Get("Content-Type") != full.Header().Get("Content-Type") {
+		t.Fatalf("expected the content type of the whole file, got %q", w.Header().Get("Content-Type"))
+	}
+
+	w = get(map[string]string{"Range": "bytes=20-"})
+
+	if w.Code != http.StatusRequestedRangeNotSatisfiable || w.Header().Get("Content-Range") != "bytes */13" {
+		t.Fatalf("expected http.StatusRequestedRangeNotSatisfiable with the size, got code %d and %v", w.Code, w.Header())
+	}
+
+	testValues := []struct {
+		headers  map[string]string
+		expected int
+	}{
+		{map[string]string{"Range": "lines=1-2"}, http.StatusOK},
+		{map[string]string{"Range": "bytes=0-", "If-Range": full.Header().Get("ETag")}, http.StatusPartialContent},
+		{map[string]string{"Range": "bytes=0-", "If-Range": full.Header().Get("Last-Modified")}, http.StatusPartialContent},
+		{map[string]string{"Range": "bytes=0-", "If-Range": `"other"`}, http.StatusOK},
+		{map[string]string{"Range": "bytes=0-", "If-Range": "W/" + full.Header().Get("ETag")}, http.StatusOK},
+		{map[string]string{"Range": "bytes=0-", "If-Range": "Mon, 28 Feb 2022 12:00:00 GMT"}, http.StatusOK},
+		// more than the whole file, through overlapping ranges
+		{map[string]string{"Range": "bytes=0-, 0-"}, http.StatusOK},
+	}
+
+	for _, v := range testValues {
+		if w := get(v.headers); w.Code != v.expected {
+			t.Fatalf("%v: expected %d, got %d", v.headers, v.expected, w.Code)
+		}
+	}
+}
+
+func TestFileHandler_HandleRequestMultipartRange(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "test_file.txt"), []byte("Hello, world!"), 0o644); err != nil {
+		t.Fatalf("error upon writing file: %s", err)
+	}
+
+	router := routing.NewRouter()
+	router.RegisterRoute("test", NewFileHandler(dir))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/test_file.txt", nil)
+	req.Header.Set("Range", "bytes=0-4, -6")
+
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected http.StatusPartialContent, got %d", w.Code)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("expected multipart/byteranges, got %q", w.Header().Get("Content-Type"))
+	}
+
+	if w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+		t.Fatalf("expected a Content-Length of %d, got %s", w.Body.Len(), w.Header().Get("Content-Length"))
+	}
+
+	expected := []struct {
+		contentRange string
+		body         string
+	}{
+		{"bytes 0-4/13", "Hello"},
+		{"bytes 7-12/13", "world!"},
+	}
+
+	reader := multipart.NewReader(w.Body, params["boundary"])
+
+	for _, e := range expected {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("expected a part for %s, got %s", e.contentRange, err)
+		}
+
+		body, _ := io.ReadAll(part)
+
+		if part.Header.Get("Content-Range") != e.contentRange || string(body) != e.body {
+			t.Fatalf("expected %s with %s, got %s with %s", e.contentRange, e.body, part.Header.Get("Content-Range"), body)
+		}
+
+		if part.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+			t.Fatalf("expected the content type of the file in every part, got %q", part.Header.Get("Content-Type"))
+		}
+	}
+
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Fatalf("expected only two parts, got %v", err)
+	}
+}
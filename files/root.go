@@ -0,0 +1,183 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errOutsideBase is the error for any path that leads outside of the
+// base path of a FileHandler, whether directly, or through a symlink.
+var errOutsideBase = errors.New("pathing outside of the base path not allowed")
+
+// errSymlinkLoop is the error for a path that goes through more symlinks
+// than maxSymlinks. Such a path never leads to a file, so it doesn't exist.
+var errSymlinkLoop = fmt.Errorf("too many levels of symbolic links: %w", fs.ErrNotExist)
+
+// maxSymlinks is how many symlinks are followed while resolving a single
+// path, before giving up on it. This is the same limit as Linux has.
+const maxSymlinks = 40
+
+// rootFS is a directory on the host's filesystem, which every path is
+// confined to, in the same way as os.Root in later versions of Go. Unlike
+// os.DirFS, symlinks are only followed as long as they stay inside of the
+// directory, so a symlink can't be used to get to files outside of it, and
+// absolute symlinks are never followed.
+//
+// Paths are resolved one component at a time, and the file that is opened
+// in the end is checked to be the same file that the path was resolved to.
+// Without openat, which os.Root uses, this can't be completely airtight
+// against someone who can change the directory while it is being served,
+// but they can only ever swap in a file, never a way out.
+type rootFS struct {
+	// dir is the path of the directory, with any symlinks in it resolved.
+	dir string
+
+	// hideDotfiles is whether any path that goes through a file whose name
+	// starts with a dot, including through a symlink, doesn't exist.
+	hideDotfiles bool
+}
+
+func newRootFS(dir string) *rootFS {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	return &rootFS{dir: dir}
+}
+
+// Open opens the file at the given path in the directory, see fs.FS.
+func (r *rootFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) || strings.ContainsRune(name, '\\') {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	resolved, info, err := r.resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: lookupError(err)}
+	}
+
+	// make sure nothing was swapped in for the
+	// file in between resolving it and opening it
+	opened, err := file.Stat()
+	if err != nil || !os.SameFile(info, opened) {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errOutsideBase}
+	}
+
+	return file, nil
+}
+
+// resolve resolves the slash-separated path into the path of a file inside
+// of the directory, following symlinks along the way, and returns the info
+// of the file it resolved to. Symlinks that lead outside of the directory,
+// or are absolute, fail with errOutsideBase. If dotfiles are hidden, a path
+// that leads through one fails with fs.ErrNotExist, no matter whether it was
+// named in the path itself, or in a symlink along the way.
+func (r *rootFS) resolve(name string) (string, fs.FileInfo, error) {
+	var pending []string
+	if name != "." {
+		pending = strings.Split(name, "/")
+	}
+
+	var resolved []string
+	var info fs.FileInfo
+	var err error
+	links := 0
+
+	for len(pending) > 0 {
+		component := pending[0]
+		pending = pending[1:]
+
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", nil, errOutsideBase
+			}
+
+			// info is of the component that was just
+			// removed, so it's looked up again at the end
+			resolved = resolved[:len(resolved)-1]
+			info = nil
+			continue
+		}
+
+		if r.hideDotfiles && strings.HasPrefix(component, ".") {
+			return "", nil, fs.ErrNotExist
+		}
+
+		current := filepath.Join(append([]string{r.dir}, append(resolved, component)...)...)
+
+		info, err = os.Lstat(current)
+		if err != nil {
+			return "", nil, lookupError(err)
+		}
+
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, component)
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", nil, errSymlinkLoop
+		}
+
+		target, err := os.Readlink(current)
+		if err != nil {
+			return "", nil, lookupError(err)
+		}
+
+		// as with os.Root, absolute symlinks are never followed
+		if filepath.IsAbs(target) || filepath.VolumeName(target) != "" {
+			return "", nil, errOutsideBase
+		}
+
+		// info is of the symlink, which a target
+		// such as . would leave it on at the end
+		info = nil
+		pending = append(strings.Split(filepath.ToSlash(target), "/"), pending...)
+	}
+
+	final := filepath.Join(append([]string{r.dir}, resolved...)...)
+
+	// the path might have ended in .., or a symlink that
+	// led nowhere new, or resolved back to the directory itself
+	if info == nil {
+		if info, err = os.Lstat(final); err != nil {
+			return "", nil, lookupError(err)
+		}
+	}
+
+	return final, info, nil
+}
+
+// lookupError gets the error of looking up a path from the error of the
+// call that failed. A path that goes through something other than a
+// directory, or through too many symlinks, doesn't exist, the same as a
+// path that leads to nothing.
+func lookupError(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOTDIR):
+		return fs.ErrNotExist
+	case errors.Is(err, syscall.ELOOP):
+		return errSymlinkLoop
+	}
+
+	return errors.Unwrap(err)
+}
@@ -0,0 +1,288 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const secret = "do not serve this"
+
+// rootTree creates a directory with a secret file next to it, and returns
+// the directory that should be served:
+//
+//	outside/secret.txt
+//	root/public.txt
+//	root/my file.txt
+//	root/.env
+//	root/.git/config
+//	root/sub/inner.txt
+//	root/sub/dir/up -> ..
+//	root/link_in    -> sub
+//	root/page.css   -> public.txt
+//	root/link_git   -> .git
+//	root/link_env   -> sub/../.env
+//	root/link_out   -> ../outside
+//	root/link_deep  -> sub/../../outside/secret.txt
+//	root/link_abs   -> /.../root/public.txt
+//	root/loop       -> loop
+//	root/self       -> .
+func rootTree(t testing.TB) string {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+
+	for name, content := range map[string]string{
+		"outside/secret.txt": secret,
+		"root/public.txt":    "public",
+		"root/my file.txt":   "spaces",
+		"root/.env":          "env",
+		"root/.git/config":   "config",
+		"root/sub/inner.txt": "inner",
+		"root/sub/dir/.keep": "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("error upon creating directory: %s", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("error upon writing file: %s", err)
+		}
+	}
+
+	for link, target := range map[string]string{
+		"sub/dir/up": "..",
+		"link_in":    "sub",
+		"page.css":   "public.txt",
+		"link_git":   ".git",
+		"link_env":   filepath.Join("sub", "..", ".env"),
+		"link_out":   filepath.Join("..", "outside"),
+		"link_deep":  filepath.Join("sub", "..", "..", "outside", "secret.txt"),
+		"link_abs":   filepath.Join(root, "public.txt"),
+		"loop":       "loop",
+		"self":       ".",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skipf("symlinks are not supported here: %s", err)
+		}
+	}
+
+	return root
+}
+
+func TestRootFS_Open(t *testing.T) {
+	fsys := newRootFS(rootTree(t))
+
+	testValues := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{"public.txt", "public", nil},
+		{"link_in/inner.txt", "inner", nil},
+		{"sub/dir/up", "", nil},
+		{"self", "", nil},
+		{"self/self/public.txt", "public", nil},
+		{"sub/dir/up/inner.txt", "inner", nil},
+		{"link_in/dir/up/inner.txt", "inner", nil},
+		{"link_out/secret.txt", "", errOutsideBase},
+		{"link_deep", "", errOutsideBase},
+		{"link_abs", "", errOutsideBase},
+		{"missing.txt", "", os.ErrNotExist},
+		{"public.txt/x", "", os.ErrNotExist},
+		{"loop", "", os.ErrNotExist},
+		{"loop/public.txt", "", os.ErrNotExist},
+		{"../outside/secret.txt", "", os.ErrInvalid},
+		{`sub\..\..\outside\secret.txt`, "", os.ErrInvalid},
+	}
+
+	for _, v := range testValues {
+		file, err := fsys.Open(v.name)
+
+		if !errors.Is(err, v.err) {
+			t.Fatalf("%s: expected error %v, got %v", v.name, v.err, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		buf := make([]byte, 64)
+		n, _ := file.Read(buf)
+		file.Close()
+
+		if string(buf[:n]) != v.body {
+			t.Fatalf("%s: expected %s, got %s", v.name, v.body, buf[:n])
+		}
+	}
+}
+
+func TestFileHandler_HandleRequestEscapes(t *testing.T) {
+	f := NewFileHandler(rootTree(t))
+
+	testValues := []struct {
+		url      string
+		expected int
+	}{
+		{"https://test.org/test/my%20file.txt", http.StatusOK},
+		{"https://test.org/test/sub/inner.txt", http.StatusOK},
+		{"https://test.org/test/link_in/inner.txt", http.StatusOK},
+		{"https://test.org/test/sub/dir/up/inner.txt", http.StatusOK},
+		{"https://test.org/test/%2e%2e/outside/secret.txt", http.StatusForbidden},
+		{"https://test.org/test/sub%2F..%2F..%2Foutside%2Fsecret.txt", http.StatusForbidden},
+		{"https://test.org/test/sub%2Finner.txt", http.StatusForbidden},
+		{"https://test.org/test/..%5C..%5Coutside%5Csecret.txt", http.StatusForbidden},
+		{"https://test.org/test/public.txt%00.html", http.StatusForbidden},
+		{"https://test.org/test/link_out/secret.txt", http.StatusForbidden},
+		{"https://test.org/test/link_deep", http.StatusForbidden},
+		{"https://test.org/test/link_abs", http.StatusForbidden},
+		{"https://test.org/test/public.txt/x", http.StatusNotFound},
+		{"https://test.org/test/loop", http.StatusNotFound},
+	}
+
+	for _, v := range testValues {
+		w := getPath(f, v.url, "")
+
+		if w.Code != v.expected {
+			t.Fatalf("%s: expected %d, got %d with %s", v.url, v.expected, w.Code, w.Body.String())
+		}
+
+		if strings.Contains(w.Body.String(), secret) {
+			t.Fatalf("%s: served the secret", v.url)
+		}
+	}
+}
+
+// a symlink is sent as the file it was requested as,
+// rather than the file that it leads to
+func TestFileHandler_HandleRequestSymlinkType(t *testing.T) {
+	f := NewFileHandler(rootTree(t))
+
+	if w := getPath(f, "https://test.org/test/page.css", ""); !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("expected text/css, got %q", w.Header().Get("Content-Type"))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/test/page.css", nil)
+	req.Header.Set("Range", "bytes=0-1")
+
+	if w := getRequest(f, req); w.Code != http.StatusPartialContent || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("expected a range of text/css, got %d and %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestFileHandler_SetHideDotfiles(t *testing.T) {
+	f := NewFileHandler(rootTree(t))
+
+	if w := getPath(f, "https://test.org/test/.env", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected dotfiles to be served by default, got %d", w.Code)
+	}
+
+	f.SetHideDotfiles(true)
+
+	for _, url := range []string{
+		"https://test.org/test/.env",
+		"https://test.org/test/%2eenv",
+		"https://test.org/test/.git/config",
+		"https://test.org/test/sub/../.git/",
+		"https://test.org/test/link_git/config",
+		"https://test.org/test/link_git/",
+		"https://test.org/test/link_env",
+	} {
+		if w := getPath(f, url, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected http.StatusNotFound, got %d", url, w.Code)
+		}
+	}
+
+	if w := getPath(f, "https://test.org/test/link_in/inner.txt", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected symlinks to other files to still be followed, got %d", w.Code)
+	}
+
+	f.SetListings(true)
+	w := getPath(f, "https://test.org/test/", "")
+
+	if strings.Contains(w.Body.String(), ".env") || strings.Contains(w.Body.String(), ".git") {
+		t.Fatalf("expected dotfiles to be left out of the listing:\n%s", w.Body.String())
+	}
+
+	if !strings.Contains(w.Body.String(), "public.txt") {
+		t.Fatalf("expected other files in the listing:\n%s", w.Body.String())
+	}
+}
+
+// pathSeeds are paths that have tried to get out of the base path.
+var pathSeeds = []string{
+	"public.txt",
+	"../outside/secret.txt",
+	"%2e%2e/outside/secret.txt",
+	"..%2Foutside%2Fsecret.txt",
+	"..%5Coutside%5Csecret.txt",
+	"sub/../../outside/secret.txt",
+	"link_out/secret.txt",
+	"link_in/../link_out/secret.txt",
+	"link_deep",
+	"link_abs",
+	"loop/x",
+	"self",
+	"self/../outside/secret.txt",
+	"%00",
+	".%2e/.%2e/outside/secret.txt",
+	"/../outside/secret.txt",
+	"//outside/secret.txt",
+}
+
+func FuzzRootFS_Open(f *testing.F) {
+	root := rootTree(f)
+	fsys := newRootFS(root)
+
+	secretInfo, err := os.Stat(filepath.Join(root, "..", "outside", "secret.txt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, seed := range pathSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		file, err := fsys.Open(name)
+		if err != nil {
+			return
+		}
+
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatalf("%q: error upon stat: %s", name, err)
+		}
+
+		if os.SameFile(info, secretInfo) {
+			t.Fatalf("%q: opened a file outside of the root", name)
+		}
+	})
+}
+
+func FuzzFileHandler_HandleRequest(f *testing.F) {
+	handler := NewFileHandler(rootTree(f))
+
+	for _, seed := range pathSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, path string) {
+		req, err := http.NewRequest(http.MethodGet, "https://test.org/test/"+path, nil)
+		if err != nil {
+			return
+		}
+
+		w := getRequest(handler, req)
+
+		if strings.Contains(w.Body.String(), secret) {
+			t.Fatalf("%q: served a file outside of the base path", path)
+		}
+	})
+}
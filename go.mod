@@ -2,8 +2,18 @@ module den
 
 go 1.18
 
-require den/routing v0.0.0
+require (
+	den/files v0.0.0
+	den/pages v1.0.0
+	den/pages/handlers v0.0.0
+	den/routing v0.0.0
+)
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
 
-replace den/routing => ./routing
+replace (
+	den/files => ./files
+	den/pages => ./pages
+	den/pages/handlers => ./pages/handlers
+	den/routing => ./routing
+)
@@ -3,6 +3,10 @@ package den
 import (
 	"den/routing"
 	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
 )
 
 // intense levels of reflection fuckery,
@@ -22,23 +26,191 @@ import (
 // spooky!
 
 // DO NOT MODIFY AFTER GO INIT FUNCTIONS
-// todo: add a write lock to this that's permanently locked
-// todo: make the databases more generic
+// (call Seal once they've run, and this is enforced)
 
 // RoutingHandlers is a database that stores all the routing handlers
-var RoutingHandlers HandlerDatabase
+var RoutingHandlers = HandlerDatabase{kind: "handler"}
 
-type HandlerDatabase struct {
-	handlers map[string]HandlerInit
+// RequestProcessors is a database that stores all the request processors
+var RequestProcessors = Database[routing.RequestProcessor]{kind: "request processor"}
+
+// ResponseProcessors is a database that stores all the response processors
+var ResponseProcessors = Database[routing.ResponseProcessor]{kind: "response processor"}
+
+// ErrSealed is returned when adding to a database after it was sealed.
+var ErrSealed = errors.New("the database is sealed")
+
+// Database stores constructors of T by name. It is safe for concurrent use,
+// and once it is sealed, it can only be read from.
+type Database[T any] struct {
+	mu      sync.RWMutex
+	sealed  bool
+	entries map[string]Factory[T]
+
+	// kind is what T is called in error messages.
+	kind string
 }
 
+// HandlerDatabase is the database of routing handlers.
+type HandlerDatabase = Database[routing.RouteHandler]
+
 type HandlerInit func(map[interface{}]interface{}) routing.RouteHandler
 
-func (db *HandlerDatabase) Add(name string, init HandlerInit) error {
-	if _, ok := db.handlers[name]; ok {
-		return errors.New(name + " was already stored in the handler database")
+// Factory is how every entry is stored in a database, no matter if it was
+// stored through Add or through Register.
+type Factory[T any] func(map[interface{}]interface{}) (T, error)
+
+// HandlerFactory is a Factory of routing handlers.
+type HandlerFactory = Factory[routing.RouteHandler]
+
+// Add stores the raw form of a constructor in the database: the options are
+// passed in as they are, and the constructor has to check them itself.
+// Returning nil from the constructor is treated as bad options.
+func (db *Database[T]) Add(name string, init func(map[interface{}]interface{}) T) error {
+	return db.add(name, func(options map[interface{}]interface{}) (T, error) {
+		v := init(options)
+		if isNil(v) {
+			return v, errors.New("nothing was created")
+		}
+
+		return v, nil
+	})
+}
+
+func (db *Database[T]) add(name string, factory Factory[T]) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.sealed {
+		return fmt.Errorf("could not store %s: %w", name, ErrSealed)
 	}
 
-	db.handlers[name] = init
+	if _, ok := db.entries[name]; ok {
+		return errors.New(name + " was already stored in the database")
+	}
+
+	if db.entries == nil {
+		db.entries = make(map[string]Factory[T])
+	}
+
+	db.entries[name] = factory
 	return nil
 }
+
+// Get gets the factory stored under the given name.
+func (db *Database[T]) Get(name string) (Factory[T], bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	factory, ok := db.entries[name]
+	return factory, ok
+}
+
+// Names returns the name of every entry in the database, sorted.
+func (db *Database[T]) Names() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	names := make([]string, 0, len(db.entries))
+	for name := range db.entries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Seal permanently locks the database for writing, so that any further
+// calls to Add or Register fail with ErrSealed. This should be called once
+// every init function has run, e.g. at the start of main.
+func (db *Database[T]) Seal() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.sealed = true
+}
+
+// Sealed reports whether Seal has been called on the database.
+func (db *Database[T]) Sealed() bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.sealed
+}
+
+// Register stores a handler in RoutingHandlers that takes its options as
+// a typed value, instead of a raw map. The options are decoded into O through
+// DecodeOptions before init is called, so init only ever sees options that
+// have passed validation.
+func Register[O any](name string, init func(O) (routing.RouteHandler, error)) error {
+	return RegisterIn(&RoutingHandlers, name, init)
+}
+
+// RegisterIn is Register, but for any database, e.g. RequestProcessors or
+// ResponseProcessors.
+func RegisterIn[O any, T any](db *Database[T], name string, init func(O) (T, error)) error {
+	return db.add(name, func(options map[interface{}]interface{}) (T, error) {
+		var o O
+		if err := DecodeOptions(options, &o); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		return init(o)
+	})
+}
+
+// create creates a new T from the factory stored under the given name.
+// Since raw constructors tend to cast their options around a lot, a panic
+// during creation is recovered and returned as an error, as is a constructor
+// that refuses to return anything.
+func (db *Database[T]) create(name string, options map[interface{}]interface{}) (v T, err error) {
+	var zero T
+
+	factory, ok := db.Get(name)
+	if !ok {
+		return zero, fmt.Errorf("unknown %s %q", db.kindName(), name)
+	}
+
+	if options == nil {
+		options = make(map[interface{}]interface{})
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			v = zero
+			err = fmt.Errorf("bad options for %s %q: %v", db.kindName(), name, p)
+		}
+	}()
+
+	if v, err = factory(options); err != nil {
+		return zero, fmt.Errorf("bad options for %s %q: %w", db.kindName(), name, err)
+	} else if isNil(v) {
+		return zero, fmt.Errorf("bad options for %s %q: nothing was created", db.kindName(), name)
+	}
+
+	return v, nil
+}
+
+func (db *Database[T]) kindName() string {
+	if db.kind == "" {
+		return "entry"
+	}
+
+	return db.kind
+}
+
+// isNil reports whether v is a nil interface, or a nil pointer.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return rv.IsNil()
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package den
+
+import (
+	"den/routing"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestHandlerDatabase_Seal(t *testing.T) {
+	var db HandlerDatabase
+
+	init := func(map[interface{}]interface{}) routing.RouteHandler {
+		return new(routeOnly)
+	}
+
+	if err := db.Add("a", init); err != nil {
+		t.Fatalf("expected add on zero value database to succeed, got %s", err)
+	}
+
+	if err := db.Add("a", init); err == nil {
+		t.Fatalf("expected duplicate add to fail")
+	}
+
+	db.Seal()
+
+	if err := db.Add("b", init); !errors.Is(err, ErrSealed) {
+		t.Fatalf("expected ErrSealed after sealing, got %v", err)
+	}
+
+	if _, ok := db.Get("a"); !ok {
+		t.Fatalf("expected a to still be readable after sealing")
+	}
+
+	if _, ok := db.Get("b"); ok {
+		t.Fatalf("expected b to not be stored")
+	}
+}
+
+func TestHandlerDatabase_ConcurrentReads(t *testing.T) {
+	var db HandlerDatabase
+	var wg sync.WaitGroup
+
+	for _, name := range []string{"c", "a", "b"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			db.Add(name, func(map[interface{}]interface{}) routing.RouteHandler {
+				return new(routeOnly)
+			})
+			db.Get(name)
+			db.Names()
+		}(name)
+	}
+
+	wg.Wait()
+
+	names := db.Names()
+	if len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Fatalf("expected sorted names [a b c], got %v", names)
+	}
+}
+
+func TestBuiltinHandlers(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("file"), 0o644); err != nil {
+		t.Fatalf("error upon writing file: %s", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte("<p>page</p>"), 0o644); err != nil {
+		t.Fatalf("error upon writing page: %s", err)
+	}
+
+	path := writeConfig(t, `address: 127.0.0.1:8080
+routes:
+  - endpoint: static
+    handler: files
+    options:
+      path: `+dir+`
+      cache:
+        .txt:
+          maxAge: 1h
+  - endpoint: site
+    handler: pages
+    options:
+      pages:
+        about/me: `+filepath.Join(dir, "page.html")+`
+`)
+
+	server, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected config to load, got: %s", err)
+	}
+
+	testValues := []struct {
+		url          string
+		body         string
+		cacheControl string
+	}{
+		{"https://test.org/static/file.txt", "file", "max-age=3600"},
+		{"https://test.org/site/about/me", "<p>page</p>", ""},
+	}
+
+	for _, v := range testValues {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, v.url, nil)
+
+		server.Router.RouteRequest(w, req)
+
+		if w.Body.String() != v.body {
+			t.Fatalf("%s: expected %s as body, got %s", v.url, v.body, w.Body.String())
+		}
+
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != v.cacheControl {
+			t.Fatalf("%s: expected %q as Cache-Control, got %q", v.url, v.cacheControl, cacheControl)
+		}
+	}
+
+	// directories aren't listed unless listings are turned on
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/static/", nil)
+
+	if server.Router.RouteRequest(w, req); w.Code != http.StatusForbidden {
+		t.Fatalf("expected http.StatusForbidden for a directory, got %d", w.Code)
+	}
+}
+
+func TestBuiltinHandlersBadOptions(t *testing.T) {
+	path := writeConfig(t, `address: 127.0.0.1:8080
+routes:
+  - endpoint: static
+    handler: files
+    options:
+      path: `+filepath.Join(t.TempDir(), "does_not_exist")+`
+`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatalf("expected a missing directory to be rejected")
+	}
+}
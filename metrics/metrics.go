@@ -0,0 +1,272 @@
+// Package metrics collects metrics from a Router, and exposes them in the
+// Prometheus text format. Metrics is both a routing.Observer, which collects
+// the metrics, and a routing.RouteHandler, which exposes them:
+//
+//	m := metrics.NewMetrics()
+//	router.RegisterObserver(m)
+//	router.RegisterRoute("metrics", m)
+package metrics
+
+import (
+	"bytes"
+	"den/routing"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the latency histogram, in seconds.
+// These are the same as the default buckets of the Prometheus client library.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultMaxEndpoints is the amount of different endpoints that are
+// tracked by default, see SetMaxEndpoints.
+const DefaultMaxEndpoints = 100
+
+// OtherEndpoint is the endpoint label that every endpoint past the
+// maximum amount of endpoints is collected under.
+const OtherEndpoint = "other"
+
+// UnmatchedEndpoint is the endpoint label of requests that weren't routed
+// to any handler, e.g., as nothing was found for them, or as a request
+// processor responded to them first.
+const UnmatchedEndpoint = "unmatched"
+
+// DefaultEndpoint is the endpoint label of requests that were
+// routed to the default route (see routing.EndpointDefault).
+const DefaultEndpoint = "default"
+
+// Metrics counts the requests that go through a Router, the requests that
+// failed, and how long they took, by their endpoint and response type:
+//
+//	den_requests_total               counter of every request
+//	den_request_errors_total         counter of every request that failed,
+//	                                 with a 5xx status, or while being sent
+//	den_request_duration_seconds     histogram of how long requests took,
+//	                                 from being received to being sent
+//
+// Every metric has the labels endpoint (with / for routing.EndpointRoot),
+// and type (see routing.ResponseType.String). The endpoint of a request is
+// the route that it was routed to, rather than whatever the client asked
+// for, so that made up endpoints don't each get metrics of their own.
+type Metrics struct {
+	mu           sync.Mutex
+	buckets      []float64
+	maxEndpoints int
+	endpoints    map[string]bool
+	series       map[seriesKey]*series
+}
+
+type seriesKey struct {
+	endpoint     string
+	responseType string
+}
+
+// series is every metric of a single set of labels.
+type series struct {
+	requests int64
+	errors   int64
+	// counts of the histogram, by bucket, plus +Inf
+	counts []int64
+	sum    float64
+}
+
+// NewMetrics creates a new Metrics, with the DefaultBuckets.
+func NewMetrics() *Metrics {
+	return NewMetricsWithBuckets(DefaultBuckets)
+}
+
+// NewMetricsWithBuckets creates a new Metrics, with the given
+// upper bounds of the latency histogram, in seconds.
+func NewMetricsWithBuckets(buckets []float64) *Metrics {
+	m := new(Metrics)
+	m.buckets = append([]float64(nil), buckets...)
+	m.maxEndpoints = DefaultMaxEndpoints
+
+	sort.Float64s(m.buckets)
+
+	return m
+}
+
+// SetMaxEndpoints sets how many different endpoints are tracked. Endpoints
+// given to Observe could come from anywhere, so this puts a bound on how
+// many of them there can be. Once the maximum has been reached, any new
+// endpoint is tracked as OtherEndpoint.
+func (m *Metrics) SetMaxEndpoints(max int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.maxEndpoints = max
+}
+
+func (m *Metrics) StageStart(routing.StageEvent) {}
+
+// StageEnd records a request, once it has been sent.
+func (m *Metrics) StageEnd(event routing.StageEvent) {
+	if event.Stage != routing.StageSend {
+		return
+	}
+
+	endpoint := event.Route
+	switch {
+	case !event.Routed:
+		endpoint = UnmatchedEndpoint
+	case endpoint == routing.EndpointDefault:
+		endpoint = DefaultEndpoint
+	}
+
+	m.Observe(endpoint, event.ResponseType, time.Since(event.Received), event.Status >= 500 || event.Err != nil)
+}
+
+// Observe records a single request to the endpoint, with a response of the
+// given type, that took the given duration. This is called by StageEnd, but
+// can also be used to record requests that didn't go through a Router.
+func (m *Metrics) Observe(endpoint string, responseType routing.ResponseType, duration time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.series == nil {
+		m.series = make(map[seriesKey]*series)
+		m.endpoints = make(map[string]bool)
+	}
+
+	if endpoint == routing.EndpointRoot {
+		endpoint = "/"
+	}
+
+	if !m.endpoints[endpoint] {
+		if len(m.endpoints) >= m.maxEndpoints {
+			endpoint = OtherEndpoint
+		}
+
+		m.endpoints[endpoint] = true
+	}
+
+	key := seriesKey{endpoint, responseType.String()}
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{counts: make([]int64, len(m.buckets)+1)}
+		m.series[key] = s
+	}
+
+	s.requests++
+	if failed {
+		s.errors++
+	}
+
+	seconds := duration.Seconds()
+	s.sum += seconds
+	s.counts[sort.SearchFloat64s(m.buckets, seconds)]++
+}
+
+// HandleRequest responds with every metric, in the Prometheus text format.
+func (m *Metrics) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	if req.Method() != http.MethodGet && req.Method() != http.MethodHead {
+		resp := routing.CreateResponseInfo(
+			http.StatusMethodNotAllowed,
+			http.Header{"Allow": {"GET, HEAD"}},
+			routing.Text,
+			routing.EndpointError,
+			bytes.NewBufferString("method not allowed"),
+		)
+
+		return &resp, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	resp := routing.CreateResponseInfo(
+		http.StatusOK,
+		http.Header{"Content-Type": {"text/plain; version=0.0.4; charset=utf-8"}},
+		routing.Text,
+		req.RequestEndpoint(),
+		&buf,
+	)
+
+	return &resp, nil
+}
+
+// WriteTo writes every metric to w, in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]seriesKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+
+		return keys[i].responseType < keys[j].responseType
+	})
+
+	var buf bytes.Buffer
+
+	buf.WriteString("# HELP den_requests_total Requests sent, by endpoint and response type.\n")
+	buf.WriteString("# TYPE den_requests_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "den_requests_total{%s} %d\n", k.labels(), m.series[k].requests)
+	}
+
+	buf.WriteString("# HELP den_request_errors_total Requests that failed, by endpoint and response type.\n")
+	buf.WriteString("# TYPE den_request_errors_total counter\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "den_request_errors_total{%s} %d\n", k.labels(), m.series[k].errors)
+	}
+
+	buf.WriteString("# HELP den_request_duration_seconds How long requests took to be sent, by endpoint and response type.\n")
+	buf.WriteString("# TYPE den_request_duration_seconds histogram\n")
+	for _, k := range keys {
+		s := m.series[k]
+		labels := k.labels()
+
+		var cumulative int64
+		for i, count := range s.counts {
+			cumulative += count
+
+			le := math.Inf(1)
+			if i < len(m.buckets) {
+				le = m.buckets[i]
+			}
+
+			fmt.Fprintf(&buf, "den_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(le), cumulative)
+		}
+
+		fmt.Fprintf(&buf, "den_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(s.sum))
+		fmt.Fprintf(&buf, "den_request_duration_seconds_count{%s} %d\n", labels, s.requests)
+	}
+
+	return buf.WriteTo(w)
+}
+
+func (k seriesKey) labels() string {
+	return fmt.Sprintf("endpoint=\"%s\",type=\"%s\"", escapeLabel(k.endpoint), escapeLabel(k.responseType))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value, as the text format requires.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"bytes"
+	"den/routing"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRoute struct {
+	code int
+}
+
+func (t *testRoute) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	resp := routing.CreateResponseInfo(t.code, nil, routing.Html, req.RequestEndpoint(), bytes.NewBufferString("<p>hi</p>"))
+	return &resp, nil
+}
+
+func get(router *routing.Router, rawUrl string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, rawUrl, nil)
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, req)
+
+	return w
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+
+	router := routing.NewRouter()
+	router.RegisterObserver(m)
+	router.RegisterRoute("metrics", m)
+	router.RegisterRoute(routing.EndpointRoot, &testRoute{http.StatusOK})
+	router.RegisterRoute("broken", &testRoute{http.StatusBadGateway})
+
+	get(router, "https://test.org/")
+	get(router, "https://test.org/")
+	get(router, "https://test.org/broken/")
+	get(router, "https://test.org/missing/")
+
+	w := get(router, "https://test.org/metrics")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected http.StatusOK, got %d", w.Code)
+	}
+
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("expected the Prometheus text format, got %s", w.Header().Get("Content-Type"))
+	}
+
+	expected := []string{
+		"# TYPE den_requests_total counter",
+		`den_requests_total{endpoint="/",type="html"} 2`,
+		`den_requests_total{endpoint="broken",type="html"} 1`,
+		`den_requests_total{endpoint="unmatched",type="text"} 1`,
+		`den_request_errors_total{endpoint="/",type="html"} 0`,
+		`den_request_errors_total{endpoint="broken",type="html"} 1`,
+		`den_request_errors_total{endpoint="unmatched",type="text"} 0`,
+		"# TYPE den_request_duration_seconds histogram",
+		`den_request_duration_seconds_bucket{endpoint="/",type="html",le="+Inf"} 2`,
+		`den_request_duration_seconds_count{endpoint="/",type="html"} 2`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(w.Body.String(), line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, w.Body.String())
+		}
+	}
+}
+
+func TestMetrics_HandleRequestMethods(t *testing.T) {
+	router := routing.NewRouter()
+	router.RegisterRoute("metrics", NewMetrics())
+
+	req, _ := http.NewRequest(http.MethodPost, "https://test.org/metrics", nil)
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("expected http.StatusMethodNotAllowed with GET and HEAD allowed, got %d and %v", w.Code, w.Header())
+	}
+}
+
+func TestMetrics_Observe(t *testing.T) {
+	m := NewMetricsWithBuckets([]float64{1, 0.1})
+	m.SetMaxEndpoints(1)
+
+	m.Observe("a", routing.Json, 50*time.Millisecond, false)
+	m.Observe("a", routing.Json, 500*time.Millisecond, false)
+	m.Observe("b\"", routing.Json, 5*time.Second, true)
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`den_request_duration_seconds_bucket{endpoint="a",type="json",le="0.1"} 1`,
+		`den_request_duration_seconds_bucket{endpoint="a",type="json",le="1"} 2`,
+		`den_request_duration_seconds_bucket{endpoint="a",type="json",le="+Inf"} 2`,
+		`den_request_duration_seconds_sum{endpoint="a",type="json"} 0.55`,
+		`den_request_errors_total{endpoint="other",type="json"} 1`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, buf.String())
+		}
+	}
+}
+
+// made up endpoints don't take up any of the endpoints that are tracked
+func TestMetrics_MadeUpEndpoints(t *testing.T) {
+	m := NewMetrics()
+	m.SetMaxEndpoints(3)
+
+	router := routing.NewRouter()
+	router.RegisterObserver(m)
+	router.RegisterRoute("real", &testRoute{http.StatusOK})
+
+	for _, endpoint := range []string{"scan0", "scan1", "scan2", "scan3", "scan4"} {
+		get(router, "https://test.org/"+endpoint+"/")
+	}
+
+	get(router, "https://test.org/real/")
+
+	fallback := routing.NewRouter()
+	fallback.RegisterObserver(m)
+	fallback.RegisterRoute(routing.EndpointDefault, &testRoute{http.StatusOK})
+
+	get(fallback, "https://test.org/anything/")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`den_requests_total{endpoint="unmatched",type="text"} 5`,
+		`den_requests_total{endpoint="real",type="html"} 1`,
+		`den_requests_total{endpoint="default",type="html"} 1`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package den
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OptionsValidator can be implemented by an options struct given to Register,
+// in order to check anything that the struct tags can't express. It is called
+// after every field has been decoded.
+type OptionsValidator interface {
+	Validate() error
+}
+
+// OptionsError is every problem that was found while decoding a handler's
+// options, so that a configuration can be fixed in one go, rather than
+// one error at a time.
+type OptionsError []error
+
+func (e OptionsError) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// DecodeOptions decodes the raw options given to a handler into out, which
+// must be a pointer. Options are decoded as YAML, so fields are named by
+// their yaml struct tag, or by their lowercased name if they have none.
+//
+// If out points to a struct, its top level fields can also be tagged with:
+//
+//	den:"required"     the option must be given
+//	default:"value"    the YAML value to use if the option isn't given
+//
+// Options that don't correspond to any field are rejected. If out implements
+// OptionsValidator, Validate is called once everything else has passed.
+// Every problem found is returned together as an OptionsError.
+func DecodeOptions(options map[interface{}]interface{}, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("options can only be decoded into a non-nil pointer")
+	}
+
+	if options == nil {
+		options = make(map[interface{}]interface{})
+	}
+
+	var errs OptionsError
+
+	if v.Elem().Kind() == reflect.Struct {
+		errs = append(errs, applyFieldTags(options, v.Elem())...)
+	}
+
+	raw, err := yaml.Marshal(options)
+	if err != nil {
+		return OptionsError{err}
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(out); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, e := range typeErr.Errors {
+				errs = append(errs, errors.New(e))
+			}
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	if validator, ok := out.(OptionsValidator); ok {
+		if err := validator.Validate(); err != nil {
+			return OptionsError{err}
+		}
+	}
+
+	return nil
+}
+
+// applyFieldTags checks for any required options that are missing, and fills
+// in the default value of any other options that are missing.
+func applyFieldTags(options map[interface{}]interface{}, v reflect.Value) []error {
+	var errs []error
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := optionName(field)
+		if !ok {
+			continue
+		}
+
+		if _, ok := options[name]; ok {
+			continue
+		}
+
+		if field.Tag.Get("den") == "required" {
+			errs = append(errs, fmt.Errorf("missing required option %q", name))
+			continue
+		}
+
+		if def, ok := field.Tag.Lookup("default"); ok {
+			if err := yaml.Unmarshal([]byte(def), v.Field(i).Addr().Interface()); err != nil {
+				errs = append(errs, fmt.Errorf("bad default for option %q: %w", name, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// optionName gets the name of the option for a struct field, in the same
+// way the YAML decoder does. Unexported and ignored fields have no name.
+func optionName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false
+	}
+
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+
+	return strings.ToLower(field.Name), true
+}
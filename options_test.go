@@ -0,0 +1,94 @@
+package den
+
+import (
+	"den/routing"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testOptions struct {
+	Path    string `yaml:"path" den:"required"`
+	Index   string `yaml:"index" default:"index.html"`
+	Retries int    `default:"3"`
+	Hidden  bool
+}
+
+func (o *testOptions) Validate() error {
+	if strings.HasPrefix(o.Path, "/etc") {
+		return errors.New("refusing to serve /etc")
+	}
+
+	return nil
+}
+
+func TestDecodeOptions(t *testing.T) {
+	var o testOptions
+
+	err := DecodeOptions(map[interface{}]interface{}{
+		"path":   "./public",
+		"hidden": true,
+	}, &o)
+
+	if err != nil {
+		t.Fatalf("expected options to decode, got %s", err)
+	}
+
+	if o.Path != "./public" || o.Index != "index.html" || o.Retries != 3 || !o.Hidden {
+		t.Fatalf("options were not decoded correctly, got %+v", o)
+	}
+}
+
+func TestDecodeOptionsAggregatesErrors(t *testing.T) {
+	var o testOptions
+
+	err := DecodeOptions(map[interface{}]interface{}{
+		"retries": "many",
+		"unknown": 1,
+	}, &o)
+
+	var errs OptionsError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected OptionsError, got %v", err)
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors (required, type, unknown), got %d: %s", len(errs), err)
+	}
+
+	if !strings.Contains(errs[0].Error(), `missing required option "path"`) {
+		t.Fatalf("expected missing path to be reported first, got %s", errs[0])
+	}
+}
+
+func TestDecodeOptionsValidate(t *testing.T) {
+	var o testOptions
+
+	err := DecodeOptions(map[interface{}]interface{}{"path": "/etc/passwd"}, &o)
+	if err == nil || !strings.Contains(err.Error(), "refusing to serve /etc") {
+		t.Fatalf("expected Validate to be called, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	err := Register("test_register", func(o testOptions) (routing.RouteHandler, error) {
+		return &configRoute{o.Path}, nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected handler to register, got %s", err)
+	}
+
+	handler, err := RoutingHandlers.create("test_register", map[interface{}]interface{}{"path": "./public"})
+	if err != nil {
+		t.Fatalf("expected handler to be created, got %s", err)
+	}
+
+	if handler.(*configRoute).body != "./public" {
+		t.Fatalf("expected options to be passed into handler")
+	}
+
+	if _, err := RoutingHandlers.create("test_register", nil); err == nil {
+		t.Fatalf("expected missing required options to fail")
+	}
+}
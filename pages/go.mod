@@ -1,3 +1,6 @@
 module pages
 
 go 1.18
+
+require den/routing v0.0.0
+replace den/routing => ./../routing
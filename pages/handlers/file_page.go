@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// FilePageNodeHandler returns a single page from a file on disk. The file
+// is opened again on every request, so that edits to it are picked up
+// without restarting.
+type FilePageNodeHandler struct {
+	name string
+	path string
+}
+
+// NewFilePageNodeHandler returns a FilePageNodeHandler with the given name,
+// reading the file at the given path.
+func NewFilePageNodeHandler(name string, path string) *FilePageNodeHandler {
+	return &FilePageNodeHandler{name, path}
+}
+
+func (f *FilePageNodeHandler) Page(path []string) (io.Reader, error) {
+	if len(path) != 0 {
+		return nil, fs.ErrNotExist
+	}
+
+	return os.Open(f.path)
+}
+
+func (f *FilePageNodeHandler) AllPages() ([]string, error) {
+	return []string{f.name}, nil
+}
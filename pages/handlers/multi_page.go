@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"errors"
 	"io"
+	"io/fs"
 	"strings"
 )
 
@@ -21,7 +21,7 @@ func (m *MultiPageNodeHandler) Page(path []string) (io.Reader, error) {
 		return v, nil
 	}
 
-	return nil, errors.New("error fetching page")
+	return nil, fs.ErrNotExist
 }
 
 func (m *MultiPageNodeHandler) AllPages() ([]string, error) {
@@ -36,3 +36,73 @@ package pages
 // This should be generic enough to handle most requests. Anything
 // that requires special processing should probably instead
 // create its own handler.
+
+import (
+	"bytes"
+	"den/routing"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// PageHandler is a routing.RouteHandler that serves pages out of a page tree.
+// The path of the request is traversed through the tree, and the leaf that it
+// ends up at is given the rest of the path in order to fetch the page.
+type PageHandler struct {
+	tree pageTree
+
+	// responseType is the response type of every page served.
+	responseType routing.ResponseType
+}
+
+// NewPageHandler creates an empty PageHandler that serves HTML pages.
+func NewPageHandler() *PageHandler {
+	return &PageHandler{responseType: routing.Html}
+}
+
+// SetResponseType sets the response type of every page served by this handler.
+func (h *PageHandler) SetResponseType(responseType routing.ResponseType) {
+	h.responseType = responseType
+}
+
+// AddPage adds a node handler into the tree at the given slash-separated path.
+// The path "/" adds the index of the endpoint, which is served when the
+// request's path ends at the endpoint itself.
+func (h *PageHandler) AddPage(path string, handler PageNodeHandler) {
+	h.tree.addPath(strings.Split(strings.Trim(path, "/"), "/"), handler)
+}
+
+func (h *PageHandler) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	// HEAD gets the same headers as GET, and net/http drops the body
+	if req.Method() != http.MethodGet && req.Method() != http.MethodHead {
+		resp := h.errorResponse(req, http.StatusMethodNotAllowed, "method not allowed")
+		resp.Headers.Set("Allow", "GET, HEAD")
+
+		return resp, nil
+	}
+
+	handler, path := h.tree.getHandler(req.Path)
+	if handler == nil {
+		return h.errorResponse(req, http.StatusNotFound, "page not found"), nil
+	}
+
+	page, err := handler.Page(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h.errorResponse(req, http.StatusNotFound, "page not found"), nil
+	} else if err != nil {
+		// anything else is on our end, so let the router decide
+		// what the client gets to see of it
+		return nil, err
+	}
+
+	resp := routing.CreateResponseInfo(http.StatusOK, http.Header{}, h.responseType, req.RequestEndpoint(), page)
+
+	return &resp, nil
+}
+
+func (h *PageHandler) errorResponse(req *routing.RequestInfo, code int, msg string) *routing.ResponseInfo {
+	resp := routing.CreateResponseInfo(code, http.Header{}, routing.Text, req.RequestEndpoint(), bytes.NewBufferString(msg))
+
+	return &resp
+}
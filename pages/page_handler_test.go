@@ -0,0 +1,139 @@
+package pages
+
+import (
+	"den/routing"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPageHandler_HandleRequest(t *testing.T) {
+	h := NewPageHandler()
+
+	handler := new(dummyPageNodeHandler)
+	handler.toReturn = "Hello, world!"
+	h.AddPage("a/b", handler)
+
+	testValues := []struct {
+		url  string
+		code int
+	}{
+		{"https://test.org/pages/a/b", http.StatusOK},
+		{"https://test.org/pages/a", http.StatusNotFound},
+		{"https://test.org/pages/c", http.StatusNotFound},
+	}
+
+	for _, v := range testValues {
+		testUrl, _ := url.Parse(v.url)
+		req := routing.NewRequestInfo(&http.Request{
+			Method: http.MethodGet,
+			URL:    testUrl,
+		})
+
+		resp, err := h.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", v.url, err)
+		}
+
+		if resp.Code() != v.code {
+			t.Fatalf("%s: expected code %d, got %d", v.url, v.code, resp.Code())
+		}
+
+		if v.code == http.StatusOK {
+			if resp.ResponseType() != routing.Html {
+				t.Fatalf("%s: expected Html response type", v.url)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != handler.toReturn {
+				t.Fatalf("%s: expected %s as body, got %s", v.url, handler.toReturn, body)
+			}
+		}
+	}
+}
+
+type errorPageNodeHandler struct {
+	err error
+}
+
+func (e *errorPageNodeHandler) Page([]string) (io.Reader, error) {
+	return nil, e.err
+}
+
+func (e *errorPageNodeHandler) AllPages() ([]string, error) {
+	return []string{}, nil
+}
+
+func TestPageHandler_HandleRequestPageErrors(t *testing.T) {
+	missing := &fs.PathError{Op: "open", Path: "/srv/site/missing.html", Err: fs.ErrNotExist}
+	broken := &fs.PathError{Op: "open", Path: "/srv/site/secret.html", Err: fs.ErrPermission}
+
+	h := NewPageHandler()
+	h.AddPage("missing", &errorPageNodeHandler{missing})
+	h.AddPage("broken", &errorPageNodeHandler{broken})
+
+	testUrl, _ := url.Parse("https://test.org/pages/missing")
+	resp, err := h.HandleRequest(routing.NewRequestInfo(&http.Request{Method: http.MethodGet, URL: testUrl}))
+
+	if err != nil || resp.Code() != http.StatusNotFound {
+		t.Fatalf("expected http.StatusNotFound for a missing page, got %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(body), "/srv/site") {
+		t.Fatalf("expected the path on disk to stay out of the body, got %s", body)
+	}
+
+	testUrl, _ = url.Parse("https://test.org/pages/broken")
+	if _, err := h.HandleRequest(routing.NewRequestInfo(&http.Request{Method: http.MethodGet, URL: testUrl})); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected the error to be handed to the router, got %v", err)
+	}
+}
+
+func TestPageHandler_HandleRequestIndex(t *testing.T) {
+	h := NewPageHandler()
+
+	index := &dummyPageNodeHandler{"index"}
+	h.AddPage("/", index)
+	h.AddPage("a/b", &dummyPageNodeHandler{"b"})
+
+	testValues := []struct {
+		method string
+		url    string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "https://test.org/pages/", http.StatusOK, "index"},
+		{http.MethodGet, "https://test.org/pages", http.StatusOK, "index"},
+		{http.MethodHead, "https://test.org/pages/", http.StatusOK, "index"},
+		{http.MethodGet, "https://test.org/pages/a/b", http.StatusOK, "b"},
+		{http.MethodGet, "https://test.org/pages/a", http.StatusNotFound, "page not found"},
+		{http.MethodPost, "https://test.org/pages/", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+
+	for _, v := range testValues {
+		testUrl, _ := url.Parse(v.url)
+		req := routing.NewRequestInfo(&http.Request{
+			Method: v.method,
+			URL:    testUrl,
+		})
+
+		resp, err := h.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("%s %s: unexpected error: %s", v.method, v.url, err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		if resp.Code() != v.code || string(body) != v.body {
+			t.Fatalf("%s %s: expected code %d with %s, got %d with %s", v.method, v.url, v.code, v.body, resp.Code(), body)
+		}
+
+		if v.code == http.StatusMethodNotAllowed && resp.Headers.Get("Allow") != "GET, HEAD" {
+			t.Fatalf("%s %s: expected GET and HEAD to be allowed, got %q", v.method, v.url, resp.Headers.Get("Allow"))
+		}
+	}
+}
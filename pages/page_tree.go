@@ -10,6 +10,18 @@ func (t *pageTree) getHandler(path []string) (PageNodeHandler, []string) {
 	n := &t.root
 
 	for n.hasChildren() {
+		// ran out of path before reaching a leaf, so this
+		// can only be the index of the node, added at ""
+		if len(path) == 0 {
+			c := n.child("")
+			if c == nil {
+				return nil, path
+			}
+
+			n = c
+			continue
+		}
+
 		c := n.child(path[0])
 		if c == nil {
 			return nil, path
@@ -114,7 +126,9 @@ func (n *pageNode) setHandler(handler PageNodeHandler) {
 type PageNodeHandler interface {
 	// Page should always return a reader, because a leaf node of a page
 	// tree *is* a page: therefore, something must be readable from
-	// this page at least.
+	// this page at least. If there is no page at the path, the error
+	// should be fs.ErrNotExist (or wrap it), which is responded to with
+	// 404. Any other error is handed to the router as an internal error.
 	Page(path []string) (io.Reader, error)
 	// AllPages should return every page accessible from this node.
 	// It should return a set of relative paths.
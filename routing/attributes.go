@@ -0,0 +1,100 @@
+package routing
+
+import (
+	"context"
+	"sync"
+)
+
+// Attributes is a set of values that are scoped to a single request, and are
+// shared between every stage of the pipeline: a RequestProcessor can attach
+// something (e.g., the user making the request) that a RouteHandler or
+// a ResponseProcessor picks up later on.
+//
+// Values are stored and fetched through a Key, so that they are always
+// of the type the key was made for. Attributes is safe for concurrent use.
+type Attributes struct {
+	mu     sync.RWMutex
+	values map[any]any
+}
+
+// Key is a typed key into Attributes. Keys are compared by identity, so two
+// keys created with the same name are still different keys.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a new key for values of type T. The name is only used for
+// debugging purposes.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+// Set stores the value under this key in the given attributes. If the
+// attributes are nil, e.g., as the request isn't going through a Router,
+// there is nowhere to store the value, so this does nothing.
+func (k *Key[T]) Set(a *Attributes, v T) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.values == nil {
+		a.values = make(map[any]any)
+	}
+
+	a.values[k] = v
+}
+
+// Get fetches the value stored under this key in the given attributes.
+// If there is no value, or the attributes are nil, this returns the zero
+// value of T and false.
+func (k *Key[T]) Get(a *Attributes) (T, bool) {
+	var zero T
+
+	if a == nil {
+		return zero, false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	v, ok := a.values[k]
+	if !ok {
+		return zero, false
+	}
+
+	// a nil interface value is stored as nil,
+	// which can't be asserted back into T
+	t, _ := v.(T)
+	return t, true
+}
+
+// Delete removes the value stored under this key in the given attributes.
+// If the attributes are nil, this does nothing.
+func (k *Key[T]) Delete(a *Attributes) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.values, k)
+}
+
+// attributesKey is the context key for the Attributes of a request.
+type attributesKey struct{}
+
+// AttributesFrom gets the Attributes of the request that the given context
+// belongs to. Inside a RequestProcessor, this is the context of the given
+// http.Request. If the context didn't come from a Router, this returns nil.
+func AttributesFrom(ctx context.Context) *Attributes {
+	a, _ := ctx.Value(attributesKey{}).(*Attributes)
+	return a
+}
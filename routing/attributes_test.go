@@ -0,0 +1,128 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+var userKey = NewKey[string]("user")
+
+// attributeRoute passes the user from the request through every stage.
+type attributeRoute struct{}
+
+func (a *attributeRoute) ProcessRequest(req *http.Request) error {
+	attrs := AttributesFrom(req.Context())
+	if attrs == nil {
+		return errors.New("no attributes in request processor")
+	}
+
+	userKey.Set(attrs, req.Header.Get("X-User"))
+	return nil
+}
+
+func (a *attributeRoute) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	user, ok := userKey.Get(req.Attributes())
+	if !ok {
+		return nil, errors.New("no user in route handler")
+	}
+
+	resp := CreateResponseInfo(http.StatusOK, http.Header{}, Html, "attr", bytes.NewBufferString("<p>Hello</p>"))
+	resp.Headers.Set("X-Handled-For", user)
+	return &resp, nil
+}
+
+func (a *attributeRoute) ProcessResponse(resp *ResponseInfo) error {
+	user, ok := userKey.Get(resp.Attributes())
+	if !ok {
+		return errors.New("no user in response processor")
+	}
+
+	resp.Body = bytes.NewBufferString("<p>Hello, " + user + "</p>")
+	return nil
+}
+
+func TestAttributes_AcrossStages(t *testing.T) {
+	router := NewRouter()
+	handler := new(attributeRoute)
+
+	router.RegisterRequestProcessor(http.MethodGet, handler)
+	router.RegisterRoute("attr", handler)
+	router.RegisterResponseProcessor(Html, "attr", handler)
+
+	testUrl, _ := url.Parse("https://test.org/attr/")
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    testUrl,
+		Header: http.Header{"X-User": {"den"}},
+	}
+	w := httptest.NewRecorder()
+
+	router.RouteRequest(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected http.StatusOK, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w.Body.String() != "<p>Hello, den</p>" {
+		t.Fatalf("expected personalized body, got %s", w.Body.String())
+	}
+
+	if w.Header().Get("X-Handled-For") != "den" {
+		t.Fatalf("expected X-Handled-For to be den, got %s", w.Header().Get("X-Handled-For"))
+	}
+}
+
+func TestAttributes_KeysAreTyped(t *testing.T) {
+	attrs := new(Attributes)
+	countKey := NewKey[int]("count")
+	otherCountKey := NewKey[int]("count")
+
+	countKey.Set(attrs, 5)
+
+	if v, ok := countKey.Get(attrs); !ok || v != 5 {
+		t.Fatalf("expected 5, got %d", v)
+	}
+
+	if _, ok := otherCountKey.Get(attrs); ok {
+		t.Fatalf("expected keys with the same name to be different keys")
+	}
+
+	countKey.Delete(attrs)
+
+	if _, ok := countKey.Get(attrs); ok {
+		t.Fatalf("expected value to be deleted")
+	}
+
+	if _, ok := countKey.Get(AttributesFrom(context.Background())); ok {
+		t.Fatalf("expected no value outside of a router")
+	}
+}
+
+func TestAttributes_Nil(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	attrs := NewRequestInfo(req).Attributes()
+
+	// outside of a router, there are no attributes to store anything in
+	userKey.Set(attrs, "frank")
+	userKey.Delete(attrs)
+
+	if _, ok := userKey.Get(attrs); ok {
+		t.Fatalf("expected no value outside of a router")
+	}
+}
+
+func TestAttributes_NilInterface(t *testing.T) {
+	attrs := new(Attributes)
+	errKey := NewKey[error]("error")
+
+	errKey.Set(attrs, nil)
+
+	if v, ok := errKey.Get(attrs); !ok || v != nil {
+		t.Fatalf("expected a stored nil error, got %v, %t", v, ok)
+	}
+}
@@ -1,65 +1,80 @@
 package routing
 
 import (
+	"context"
+	"sync"
 	"time"
 )
 
+// routingContext is the context.Context of a single request going through
+// the router. It is derived from the request's own context (so that a client
+// disconnecting, or the router's timeout, cancels it), and it can also be
+// closed early by any stage through CloseWithError.
+//
+// Handlers get this context through RequestInfo.Context.
 type routingContext struct {
-	stage     routeStage // don't necessarily like this one
-	stageChan chan routeStage
-	info      *ResponseInfo
-	data      *ResponseData
-	err       error
-	done      chan struct{}
-}
+	ctx    context.Context
+	cancel context.CancelFunc
 
-func newRoutingContext() *routingContext {
-	ctx := new(routingContext)
-	ctx.stageChan = make(chan routeStage, 1)
-	ctx.done = make(chan struct{}, 1)
+	// attrs are the Attributes of this request, see AttributesFrom.
+	attrs *Attributes
 
-	return ctx
+	// mu guards err, as a handler may hand its
+	// context off to goroutines of its own.
+	mu  sync.Mutex
+	err error
 }
 
-func (c *routingContext) upgradeStage() {
-	if c.stage >= finish {
-		return
-	}
+func newRoutingContext(parent context.Context) *routingContext {
+	ctx := new(routingContext)
+	ctx.ctx, ctx.cancel = context.WithCancel(parent)
+	ctx.attrs = new(Attributes)
 
-	c.stageChan <- c.stage + 1
+	return ctx
 }
 
 // CloseWithError indicates that a routing function has hit a critical error,
-// and needs to finish the client's session immediately. Currently, this sends
-// a generic 500 error to the client, with the given error as the body.
+// and needs to finish the client's session immediately. This sends an error
+// response to the client, based on the given error (see HTTPError).
 //
 // Further context errors are ignored.
 func (c *routingContext) CloseWithError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.err != nil {
 		return
 	}
 
 	c.err = err
-
-	c.done <- struct{}{}
-
-	close(c.done)
+	c.cancel()
 }
 
 func (c *routingContext) Deadline() (deadline time.Time, ok bool) {
-	//TODO implement me
-	panic("implement me")
+	return c.ctx.Deadline()
 }
 
+// Err returns the error the context was closed with, or if it was instead
+// cancelled or timed out, the error of the underlying context.
 func (c *routingContext) Err() error {
-	return c.err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.ctx.Err()
 }
 
 func (c *routingContext) Value(key any) any {
-	//TODO implement me
-	panic("implement me")
+	if _, ok := key.(attributesKey); ok {
+		return c.attrs
+	}
+
+	return c.ctx.Value(key)
 }
 
 func (c *routingContext) Done() <-chan struct{} {
-	return c.done
+	return c.ctx.Done()
 }
@@ -0,0 +1,161 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// contextRoute waits for either its release channel or its request's
+// context, and records what the context looked like.
+type contextRoute struct {
+	started  chan struct{}
+	release  chan struct{}
+	deadline bool
+	value    any
+	err      chan error
+}
+
+type contextKey struct{}
+
+func (c *contextRoute) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	ctx := req.Context()
+	_, c.deadline = ctx.Deadline()
+	c.value = ctx.Value(contextKey{})
+	close(c.started)
+
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		c.err <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	resp := CreateResponseInfo(http.StatusOK, http.Header{}, Text, "ctx", bytes.NewBufferString("released"))
+	return &resp, nil
+}
+
+func newContextRequest(ctx context.Context) *http.Request {
+	testUrl, _ := url.Parse("https://test.org/ctx/")
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    testUrl,
+	}
+
+	return req.WithContext(ctx)
+}
+
+func TestRouter_Timeout(t *testing.T) {
+	router := NewRouter()
+	router.SetTimeout(10 * time.Millisecond)
+
+	handler := &contextRoute{started: make(chan struct{}), release: make(chan struct{}), err: make(chan error, 1)}
+	router.RegisterRoute("ctx", handler)
+
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, newContextRequest(context.Background()))
+
+	if err := <-handler.err; err != context.DeadlineExceeded {
+		t.Fatalf("expected handler to see context.DeadlineExceeded, got %v", err)
+	}
+
+	if !handler.deadline {
+		t.Fatalf("expected handler context to have a deadline")
+	}
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected http.StatusServiceUnavailable on timeout, got %d", w.Code)
+	}
+}
+
+// slowRoute ignores its context, and responds after a while.
+type slowRoute struct {
+	body *failingReader
+}
+
+func (s *slowRoute) HandleRequest(*RequestInfo) (*ResponseInfo, error) {
+	time.Sleep(30 * time.Millisecond)
+
+	resp := CreateResponseInfo(http.StatusOK, http.Header{}, Text, "slow", s.body)
+	return &resp, nil
+}
+
+// a handler that ignores its context entirely still has its
+// response thrown away (and closed) once it finally returns
+func TestRouter_TimeoutIgnoredContext(t *testing.T) {
+	router := NewRouter()
+	router.SetTimeout(10 * time.Millisecond)
+
+	handler := &slowRoute{&failingReader{data: bytes.NewBufferString("late")}}
+	router.RegisterRoute("slow", handler)
+
+	w := httptest.NewRecorder()
+	routeTo(router, w, "https://test.org/slow/")
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected http.StatusServiceUnavailable on timeout, got %d", w.Code)
+	}
+
+	if !handler.body.closed {
+		t.Fatalf("expected the late response body to be closed")
+	}
+
+	// the request was done with once RouteRequest returned
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := router.Drain(ctx); err != nil {
+		t.Fatalf("expected the router to be idle, got %s", err)
+	}
+}
+
+func TestRouter_ClientCancel(t *testing.T) {
+	router := NewRouter()
+
+	handler := &contextRoute{started: make(chan struct{}), release: make(chan struct{}), err: make(chan error, 1)}
+	router.RegisterRoute("ctx", handler)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "value"))
+
+	routed := make(chan struct{})
+	go func() {
+		router.RouteRequest(httptest.NewRecorder(), newContextRequest(ctx))
+		close(routed)
+	}()
+
+	<-handler.started
+	cancel()
+
+	if err := <-handler.err; err != context.Canceled {
+		t.Fatalf("expected handler to see context.Canceled, got %v", err)
+	}
+
+	<-routed
+
+	if handler.value != "value" {
+		t.Fatalf("expected request context values to reach the handler, got %v", handler.value)
+	}
+}
+
+func TestRouter_NoTimeout(t *testing.T) {
+	router := NewRouter()
+
+	handler := &contextRoute{started: make(chan struct{}), release: make(chan struct{}), err: make(chan error, 1)}
+	router.RegisterRoute("ctx", handler)
+	close(handler.release)
+
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, newContextRequest(context.Background()))
+
+	if handler.deadline {
+		t.Fatalf("expected no deadline without a timeout")
+	}
+
+	if w.Code != http.StatusOK || w.Body.String() != "released" {
+		t.Fatalf("expected http.StatusOK with released, got %d and %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,77 @@
+package routing
+
+import (
+	"context"
+	"sync"
+)
+
+// drainState keeps count of every request currently being routed. Once
+// draining has started, no new requests are accepted, and idle is closed
+// as soon as the last in-flight request reaches the finish stage.
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{}
+}
+
+// acquire marks a request as in-flight. It returns false if the router
+// is draining, in which case the request should not be routed.
+func (r *Router) acquire() bool {
+	r.drain.mu.Lock()
+	defer r.drain.mu.Unlock()
+
+	if r.drain.draining {
+		return false
+	}
+
+	r.drain.active++
+	return true
+}
+
+// release marks an in-flight request as finished.
+func (r *Router) release() {
+	r.drain.mu.Lock()
+	defer r.drain.mu.Unlock()
+
+	r.drain.active--
+
+	if r.drain.draining && r.drain.active == 0 {
+		close(r.drain.idle)
+	}
+}
+
+// Drain stops the router from accepting any new requests, and blocks until
+// every request that is currently in-flight has been sent to the client,
+// or until the given context is done. Requests that arrive after draining
+// has started are answered with http.StatusServiceUnavailable.
+//
+// Draining cannot be undone: a drained router should be discarded.
+func (r *Router) Drain(ctx context.Context) error {
+	r.drain.mu.Lock()
+	if !r.drain.draining {
+		r.drain.draining = true
+		r.drain.idle = make(chan struct{})
+
+		if r.drain.active == 0 {
+			close(r.drain.idle)
+		}
+	}
+	idle := r.drain.idle
+	r.drain.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Draining reports whether Drain has been called on this router.
+func (r *Router) Draining() bool {
+	r.drain.mu.Lock()
+	defer r.drain.mu.Unlock()
+
+	return r.drain.draining
+}
@@ -0,0 +1,102 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type blockingRoute struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingRoute) HandleRequest(*RequestInfo) (*ResponseInfo, error) {
+	close(b.started)
+	<-b.release
+
+	resp := CreateResponseInfo(http.StatusOK, http.Header{}, Text, "block", bytes.NewBufferString("done"))
+	return &resp, nil
+}
+
+func TestRouter_DrainWaitsForInFlight(t *testing.T) {
+	router := NewRouter()
+	handler := &blockingRoute{make(chan struct{}), make(chan struct{})}
+	router.RegisterRoute("block", handler)
+
+	testUrl, _ := url.Parse("https://test.org/block/")
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    testUrl,
+	}
+
+	routed := make(chan struct{})
+	go func() {
+		router.RouteRequest(httptest.NewRecorder(), req)
+		close(routed)
+	}()
+
+	<-handler.started
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- router.Drain(context.Background())
+	}()
+
+	select {
+	case <-drained:
+		t.Fatalf("Drain returned while a request was still in-flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !router.Draining() {
+		t.Fatalf("expected router to be draining")
+	}
+
+	close(handler.release)
+	<-routed
+
+	if err := <-drained; err != nil {
+		t.Fatalf("expected clean drain, got %s", err)
+	}
+}
+
+func TestRouter_DrainRejectsNewRequests(t *testing.T) {
+	router := NewRouter()
+	router.RegisterRoute("test", &testRoute{"test", http.MethodGet, Text, "Hello, world!"})
+
+	if err := router.Drain(context.Background()); err != nil {
+		t.Fatalf("expected clean drain with no requests, got %s", err)
+	}
+
+	testUrl, _ := url.Parse("https://test.org/test/")
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    testUrl,
+	}
+	w := httptest.NewRecorder()
+
+	router.RouteRequest(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected http.StatusServiceUnavailable while draining, got %d", w.Code)
+	}
+}
+
+func TestRouter_DrainTimeout(t *testing.T) {
+	router := NewRouter()
+	router.acquire()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := router.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	router.release()
+}
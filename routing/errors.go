@@ -0,0 +1,141 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// HTTPError is an error that knows how it should be shown to the client.
+// It can be returned from any stage of the router (request processors,
+// route handlers, middleware, and response processors), and the client
+// receives an error response with its status code and public message.
+//
+// Any other error returned from those stages is treated as an
+// http.StatusInternalServerError, with the internal error as its cause.
+type HTTPError struct {
+	// Code is the HTTP status code of the error response.
+	Code int
+
+	// Message is the message that is shown to the client. If this is
+	// empty, the standard text of the status code is used instead.
+	Message string
+
+	// Err is the internal cause of the error, if any. This is only shown
+	// to the client if the router isn't in production mode, otherwise it
+	// is logged instead. See Router.SetProductionMode.
+	Err error
+}
+
+// NewHTTPError creates a new HTTPError with the given status code, public
+// message, and internal cause. The message and cause are both optional.
+func NewHTTPError(code int, message string, cause error) *HTTPError {
+	return &HTTPError{
+		Code:    code,
+		Message: message,
+		Err:     cause,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%d %s", e.Code, e.PublicMessage())
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.Code, e.PublicMessage(), e.Err)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// PublicMessage is the message that is safe to show to the client.
+func (e *HTTPError) PublicMessage() string {
+	if e.Message == "" {
+		return http.StatusText(e.Code)
+	}
+
+	return e.Message
+}
+
+// AsHTTPError gets the HTTPError of any error that went through the router,
+// in the same way that the router itself does: if there's an HTTPError in the
+// error's chain, that is used, otherwise, the error is turned into one.
+//
+// Errors from a request timing out, or being cancelled, are shown as an
+// http.StatusServiceUnavailable, and any other error is shown as an
+// http.StatusInternalServerError, with the error as its cause.
+func AsHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewHTTPError(http.StatusServiceUnavailable, "request timed out", err)
+	case errors.Is(err, context.Canceled):
+		return NewHTTPError(http.StatusServiceUnavailable, "request cancelled", err)
+	}
+
+	return NewHTTPError(http.StatusInternalServerError, "", err)
+}
+
+// SetProductionMode sets whether the router is in production mode. In
+// production mode, the internal cause of an error is never sent to the
+// client, and is written to the router's error log instead.
+func (r *Router) SetProductionMode(production bool) {
+	r.production = production
+}
+
+// SetErrorLog sets the logger that the router writes internal errors to.
+// If this is nil, the standard logger of the log package is used.
+func (r *Router) SetErrorLog(logger *log.Logger) {
+	r.errorLog = logger
+}
+
+// logf writes to the error log of the router.
+func (r *Router) logf(format string, v ...any) {
+	if r.errorLog == nil {
+		log.Printf(format, v...)
+		return
+	}
+
+	r.errorLog.Printf(format, v...)
+}
+
+// errorResponse creates the response that is sent to the client for an error
+// that occurred while routing a request. See AsHTTPError.
+func (r *Router) errorResponse(req *http.Request, err error) *ResponseInfo {
+	httpErr := AsHTTPError(err)
+	msg := httpErr.PublicMessage()
+
+	if httpErr.Err != nil {
+		if r.production {
+			// panics are already logged, stack trace and all,
+			// unless they went to a PanicHandler instead
+			var panicErr *PanicError
+			if !errors.As(httpErr.Err, &panicErr) || r.panicHandler != nil {
+				r.logf("den: %s %s: %s", req.Method, req.URL, httpErr)
+			}
+
+			httpErr = NewHTTPError(httpErr.Code, httpErr.Message, nil)
+		} else {
+			msg = fmt.Sprintf("%s: %s", msg, httpErr.Err)
+		}
+	}
+
+	resp := CreateGenericErrorResponse(httpErr.Code, msg)
+	resp.err = httpErr
+
+	// an error from a mounted router goes back
+	// through it, as its response would have
+	var mounted *mountedError
+	if errors.As(err, &mounted) {
+		resp.mounted = mounted.mounted
+	}
+
+	return resp
+}
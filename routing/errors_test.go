@@ -0,0 +1,114 @@
+package routing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// errorRoute fails with its error at the given stage.
+type errorRoute struct {
+	err        error
+	onRequest  bool
+	onRoute    bool
+	onResponse bool
+}
+
+func (e *errorRoute) ProcessRequest(*http.Request) error {
+	if e.onRequest {
+		return e.err
+	}
+
+	return nil
+}
+
+func (e *errorRoute) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	if e.onRoute {
+		return nil, e.err
+	}
+
+	return pathRoute{}.HandleRequest(req)
+}
+
+func (e *errorRoute) ProcessResponse(*ResponseInfo) error {
+	if e.onResponse {
+		return e.err
+	}
+
+	return nil
+}
+
+func TestRouter_HTTPError(t *testing.T) {
+	cause := errors.New("database is on fire")
+
+	tests := []struct {
+		name  string
+		route *errorRoute
+		code  int
+		body  string
+	}{
+		{"request", &errorRoute{err: NewHTTPError(http.StatusUnauthorized, "who are you?", nil), onRequest: true}, http.StatusUnauthorized, "who are you?"},
+		{"route", &errorRoute{err: NewHTTPError(http.StatusTeapot, "", nil), onRoute: true}, http.StatusTeapot, "I'm a teapot"},
+		{"response", &errorRoute{err: NewHTTPError(http.StatusBadGateway, "upstream", cause), onResponse: true}, http.StatusBadGateway, "upstream: database is on fire"},
+		{"untyped", &errorRoute{err: cause, onRoute: true}, http.StatusInternalServerError, "Internal Server Error: database is on fire"},
+	}
+
+	for _, test := range tests {
+		router := NewRouter()
+		router.RegisterRequestProcessor(http.MethodGet, test.route)
+		router.RegisterRoute("users", test.route)
+		router.RegisterResponseProcessor(Text, "users", test.route)
+
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+		w := routeRecorder(router, req)
+
+		if w.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, w.Code)
+		}
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected %q, got %q", test.name, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_HTTPErrorNotFound(t *testing.T) {
+	router := NewRouter()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/missing/", nil)
+	if w := routeRecorder(router, req); w.Code != http.StatusNotFound {
+		t.Fatalf("expected http.StatusNotFound, got %d", w.Code)
+	}
+}
+
+func TestRouter_SetProductionMode(t *testing.T) {
+	var logs bytes.Buffer
+
+	router := NewRouter()
+	router.SetProductionMode(true)
+	router.SetErrorLog(log.New(&logs, "", 0))
+	router.RegisterRoute("users", &errorRoute{err: errors.New("secret"), onRoute: true})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	w := routeRecorder(router, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected http.StatusInternalServerError, got %d", w.Code)
+	}
+
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Fatalf("expected the cause to be hidden, got %q", w.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), "secret") {
+		t.Fatalf("expected the cause to be logged, got %q", logs.String())
+	}
+}
+
+func nopLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
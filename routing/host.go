@@ -0,0 +1,246 @@
+package routing
+
+import (
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"sort"
+	"strings"
+)
+
+// HostResolver decides which part of a request's host, if any, is the
+// endpoint of the request. A host is made up of a base domain, and the
+// subdomain in front of it: for a base domain of example.com, a request to
+// api.example.com is routed to the endpoint api, and a request to
+// example.com is routed by the first section of its path instead.
+//
+// Base domains are either given up front, or found through a public suffix
+// list (e.g., golang.org/x/net/publicsuffix.List), so that hosts such as
+// api.example.co.uk work as intended. Once base domains are given, a host
+// that falls under none of them (and isn't found through the public suffix
+// list, if there is one) is routed by its path, so that a client can't pick
+// an endpoint through a made-up Host header. Without base domains or a public
+// suffix list, the last two labels of a host are its base domain. Hosts that
+// are IP addresses, or that don't have a base domain (e.g., localhost) are
+// always routed by their path.
+//
+// If the whole subdomain is more than one label (e.g., a.b.example.com), the
+// endpoint is the whole subdomain (a.b). The www subdomain is never treated
+// as an endpoint, unless it is explicitly allowed.
+//
+// A HostResolver should be fully set up before it's given to a Router, as it
+// isn't safe to change while requests are being routed.
+type HostResolver struct {
+	// hosts maps base domains to the router that holds their endpoint
+	// tables, or to nil for the router the resolver belongs to.
+	hosts map[string]*Router
+	// domains are the keys of hosts, longest first,
+	// as the longest base domain wins.
+	domains []string
+
+	// suffixes is used to find the base domain of any host
+	// that doesn't fall under one of the known base domains.
+	suffixes cookiejar.PublicSuffixList
+
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+// NewHostResolver creates a new HostResolver with the given base domains.
+func NewHostResolver(baseDomains ...string) *HostResolver {
+	resolver := new(HostResolver)
+	resolver.AddBaseDomain(baseDomains...)
+
+	return resolver
+}
+
+// defaultHostResolver is used by any Router that hasn't been given
+// a HostResolver, and by NewRequestInfo.
+var defaultHostResolver = NewHostResolver()
+
+// AddBaseDomain adds base domains to the resolver, whose subdomains are
+// routed to the endpoints of the resolver's Router.
+func (h *HostResolver) AddBaseDomain(domains ...string) {
+	for _, domain := range domains {
+		h.addHost(domain, nil)
+	}
+}
+
+// AddVirtualHost adds a base domain whose requests are routed to the endpoints
+// registered to the given router, rather than to the endpoints of the
+// resolver's own Router. Subdomains of a virtual host are resolved as they
+// would be for any other base domain. If a host falls under more than one
+// base domain, the longest one wins.
+//
+// Only the routes and patterns of the given router are used: requests still
+// go through the request and response processors of the resolver's Router.
+func (h *HostResolver) AddVirtualHost(domain string, router *Router) {
+	h.addHost(domain, router)
+}
+
+func (h *HostResolver) addHost(domain string, router *Router) {
+	if h.hosts == nil {
+		h.hosts = make(map[string]*Router)
+	}
+
+	domain = normalizeHost(domain)
+	if _, ok := h.hosts[domain]; !ok {
+		h.domains = append(h.domains, domain)
+
+		sort.SliceStable(h.domains, func(i, j int) bool {
+			return len(h.domains[i]) > len(h.domains[j])
+		})
+	}
+
+	h.hosts[domain] = router
+}
+
+// SetPublicSuffixList sets the public suffix list that is used to find the
+// base domain of hosts that don't fall under any of the known base domains.
+func (h *HostResolver) SetPublicSuffixList(list cookiejar.PublicSuffixList) {
+	h.suffixes = list
+}
+
+// Allow adds subdomains to the allow list. Once there is anything in the
+// allow list, only the subdomains in it are treated as endpoints, and any
+// other request is routed by its path instead.
+func (h *HostResolver) Allow(subdomains ...string) {
+	if h.allow == nil {
+		h.allow = make(map[string]bool)
+	}
+
+	for _, subdomain := range subdomains {
+		h.allow[strings.ToLower(subdomain)] = true
+	}
+}
+
+// Deny adds subdomains to the deny list. Requests to these subdomains are
+// routed by their path instead, as if there was no subdomain at all.
+func (h *HostResolver) Deny(subdomains ...string) {
+	if h.deny == nil {
+		h.deny = make(map[string]bool)
+	}
+
+	for _, subdomain := range subdomains {
+		h.deny[strings.ToLower(subdomain)] = true
+	}
+}
+
+// Resolve returns the subdomain of the host that should be used as the
+// endpoint of a request, and whether there is one at all. The host may
+// contain a port.
+func (h *HostResolver) Resolve(host string) (string, bool) {
+	_, subdomain, ok := h.resolve(host)
+	return subdomain, ok
+}
+
+// resolve returns the router whose endpoint tables the host is routed to
+// (nil for the resolver's own), and the subdomain that is the endpoint.
+func (h *HostResolver) resolve(host string) (*Router, string, bool) {
+	host = normalizeHost(host)
+
+	if host == "" || net.ParseIP(host) != nil {
+		return nil, "", false
+	}
+
+	router, subdomain, ok := h.split(host)
+	if !ok || subdomain == "" || !h.allowed(subdomain) {
+		return router, "", false
+	}
+
+	return router, subdomain, true
+}
+
+// allowed reports whether the subdomain can be used as an endpoint.
+func (h *HostResolver) allowed(subdomain string) bool {
+	if h.deny[subdomain] {
+		return false
+	}
+
+	if h.allow != nil {
+		return h.allow[subdomain]
+	}
+
+	return subdomain != "www"
+}
+
+// split splits the host into its subdomain, and the router of its base domain.
+func (h *HostResolver) split(host string) (*Router, string, bool) {
+	for _, domain := range h.domains {
+		if host == domain {
+			return h.hosts[domain], "", true
+		}
+
+		if strings.HasSuffix(host, "."+domain) {
+			return h.hosts[domain], strings.TrimSuffix(host, "."+domain), true
+		}
+	}
+
+	if h.suffixes != nil {
+		suffix := h.suffixes.PublicSuffix(host)
+		if suffix == host || !strings.HasSuffix(host, "."+suffix) {
+			return nil, "", false
+		}
+
+		labels := strings.Split(strings.TrimSuffix(host, "."+suffix), ".")
+		return nil, strings.Join(labels[:len(labels)-1], "."), true
+	}
+
+	// an unknown host can't be trusted to have an endpoint
+	// in it, once we know what the base domains are
+	if len(h.domains) > 0 {
+		return nil, "", false
+	}
+
+	// without anything else to go off of, the last two labels are the base
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return nil, "", false
+	}
+
+	return nil, strings.Join(labels[:len(labels)-2], "."), true
+}
+
+// normalizeHost strips the port and any trailing dot from a host,
+// and lowercases it.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.TrimPrefix(host, "[")
+	host = strings.TrimSuffix(host, "]")
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// requestHost gets the host that a request was made to. Requests received by
+// a server only have their host in Host, while client requests may only have
+// it in their URL.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	if req.URL != nil {
+		return req.URL.Host
+	}
+
+	return ""
+}
+
+// SetHostResolver sets the HostResolver the router uses to find the
+// endpoint of a request from its host. Without one, the router uses
+// a HostResolver with no base domains (see NewHostResolver).
+func (r *Router) SetHostResolver(resolver *HostResolver) {
+	r.hosts = resolver
+}
+
+// hostResolver gets the HostResolver of the router.
+func (r *Router) hostResolver() *HostResolver {
+	if r.hosts == nil {
+		return defaultHostResolver
+	}
+
+	return r.hosts
+}
@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// testSuffixes is a tiny public suffix list, which only knows co.uk.
+type testSuffixes struct{}
+
+func (testSuffixes) PublicSuffix(domain string) string {
+	if domain == "co.uk" || strings.HasSuffix(domain, ".co.uk") {
+		return "co.uk"
+	}
+
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+func (testSuffixes) String() string {
+	return "test"
+}
+
+func TestHostResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		resolver  *HostResolver
+		host      string
+		subdomain string
+		ok        bool
+	}{
+		{"default", NewHostResolver(), "api.test.org", "api", true},
+		{"default root", NewHostResolver(), "test.org", "", false},
+		{"www", NewHostResolver(), "www.test.org", "", false},
+		{"port", NewHostResolver(), "api.test.org:8080", "api", true},
+		{"localhost", NewHostResolver(), "localhost:8080", "", false},
+		{"ipv4", NewHostResolver(), "127.0.0.1:8080", "", false},
+		{"ipv6", NewHostResolver(), "[::1]:8080", "", false},
+		{"case", NewHostResolver(), "API.Test.org.", "api", true},
+		{"base domain", NewHostResolver("example.co.uk"), "api.example.co.uk", "api", true},
+		{"base domain root", NewHostResolver("example.co.uk"), "example.co.uk", "", false},
+		{"nested", NewHostResolver("example.com"), "a.b.example.com", "a.b", true},
+		{"longest base domain", NewHostResolver("example.com", "api.example.com"), "v1.api.example.com", "v1", true},
+		{"not a base domain", NewHostResolver("example.com"), "anything.attacker.tld", "", false},
+		{"not a base domain with suffixes", withSuffixes(NewHostResolver("example.com")), "api.example.co.uk", "api", true},
+		{"unknown", withSuffixes(NewHostResolver()), "api.example.co.uk", "api", true},
+		{"unknown root", withSuffixes(NewHostResolver()), "example.co.uk", "", false},
+		{"suffix only", withSuffixes(NewHostResolver()), "co.uk", "", false},
+		{"allowed", withAllow(NewHostResolver(), "api"), "api.test.org", "api", true},
+		{"not allowed", withAllow(NewHostResolver(), "api"), "blog.test.org", "", false},
+		{"allowed www", withAllow(NewHostResolver(), "www"), "www.test.org", "www", true},
+		{"denied", withDeny(NewHostResolver(), "cdn"), "cdn.test.org", "", false},
+	}
+
+	for _, test := range tests {
+		subdomain, ok := test.resolver.Resolve(test.host)
+
+		if subdomain != test.subdomain || ok != test.ok {
+			t.Errorf("%s: expected %q, %t for %s, got %q, %t", test.name, test.subdomain, test.ok, test.host, subdomain, ok)
+		}
+	}
+}
+
+func withSuffixes(h *HostResolver) *HostResolver {
+	h.SetPublicSuffixList(testSuffixes{})
+	return h
+}
+
+func withAllow(h *HostResolver, subdomains ...string) *HostResolver {
+	h.Allow(subdomains...)
+	return h
+}
+
+func withDeny(h *HostResolver, subdomains ...string) *HostResolver {
+	h.Deny(subdomains...)
+	return h
+}
+
+func TestRouter_SetHostResolver(t *testing.T) {
+	blog := NewRouter()
+	blog.RegisterRoute(EndpointRoot, &paramRoute{"blog root"})
+	blog.RegisterRoute("posts", &paramRoute{"blog posts"})
+
+	resolver := NewHostResolver("example.co.uk")
+	resolver.AddVirtualHost("blog.example.co.uk", blog)
+
+	router := NewRouter()
+	router.SetHostResolver(resolver)
+	router.RegisterRoute(EndpointRoot, &paramRoute{"root"})
+	router.RegisterRoute("api", &paramRoute{"api"})
+
+	tests := []struct {
+		host string
+		path string
+		body string
+	}{
+		{"example.co.uk", "/", "root "},
+		{"www.example.co.uk", "/api/", "api "},
+		{"api.example.co.uk:8080", "/users/", "api "},
+		{"blog.example.co.uk", "/", "blog root "},
+		{"blog.example.co.uk", "/posts/1", "blog posts "},
+		{"posts.blog.example.co.uk", "/", "blog posts "},
+		{"127.0.0.1:8080", "/api/", "api "},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+test.host+test.path, nil)
+		w := routeRecorder(router, req)
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s%s: expected %q, got %q", test.host, test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_HostFromRequest(t *testing.T) {
+	router := NewRouter()
+	router.RegisterRoute("api", &paramRoute{"api"})
+
+	// server requests only carry the host in req.Host
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "api.test.org"
+
+	if w := routeRecorder(router, req); w.Body.String() != "api " {
+		t.Fatalf("expected the api endpoint, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,48 @@
+package routing
+
+import "net/http"
+
+// Middleware wraps a RouteHandler in another RouteHandler, which can do
+// anything before or after calling the next handler, or respond on its own
+// without calling the next handler at all (e.g., for authentication).
+type Middleware func(next RouteHandler) RouteHandler
+
+// RouteHandlerFunc lets an ordinary function be used as a RouteHandler.
+type RouteHandlerFunc func(req *RequestInfo) (*ResponseInfo, error)
+
+func (f RouteHandlerFunc) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	return f(req)
+}
+
+// Chain wraps the handler in every middleware given, so that the first
+// middleware is the first to see the request.
+func Chain(handler RouteHandler, middleware ...Middleware) RouteHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// Use adds middleware to the router, which wraps the handler of every request
+// that the router routes, outside of any middleware that was registered to the
+// route itself. Middleware added first is the first to see the request.
+//
+// Middleware only sees the requests that reach the routing stage, so it runs
+// after the request processors. The middleware of a mounted router runs inside
+// the middleware of the router it is mounted in.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// RequestResponder can be implemented by a RequestProcessor in order to
+// respond to a request before it's routed, e.g., with a redirect, a cached
+// response, or an authentication challenge. If a request processor is a
+// RequestResponder, RespondRequest is called in place of ProcessRequest.
+//
+// If RespondRequest returns a response, the request skips routing entirely,
+// and the response goes straight to post process. If it returns nil, the
+// request carries on to the next request processor as normal.
+type RequestResponder interface {
+	RespondRequest(req *http.Request) (*ResponseInfo, error)
+}
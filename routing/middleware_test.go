@@ -0,0 +1,118 @@
+package routing
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// tagMiddleware adds its name to the X-Order header of the request on the
+// way in, and to the X-Order header of the response on the way out.
+func tagMiddleware(name string) Middleware {
+	return func(next RouteHandler) RouteHandler {
+		return RouteHandlerFunc(func(req *RequestInfo) (*ResponseInfo, error) {
+			req.Headers().Add("X-Order", name)
+
+			resp, err := next.HandleRequest(req)
+			if err == nil {
+				resp.Headers.Add("X-Order", name)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+// denyMiddleware responds with http.StatusUnauthorized, unless the request
+// has an Authorization header.
+func denyMiddleware(next RouteHandler) RouteHandler {
+	return RouteHandlerFunc(func(req *RequestInfo) (*ResponseInfo, error) {
+		if req.Headers().Get("Authorization") == "" {
+			return CreateGenericErrorResponse(http.StatusUnauthorized, "unauthorized"), nil
+		}
+
+		return next.HandleRequest(req)
+	})
+}
+
+// redirectProcessor redirects any request to /old.
+type redirectProcessor struct {
+	processed bool
+}
+
+func (r *redirectProcessor) ProcessRequest(*http.Request) error {
+	r.processed = true
+	return nil
+}
+
+func (r *redirectProcessor) RespondRequest(req *http.Request) (*ResponseInfo, error) {
+	if req.URL.Path != "/old" {
+		return nil, nil
+	}
+
+	resp := CreateResponseInfo(http.StatusFound, http.Header{"Location": {"/new"}}, None, EndpointRoot, nil)
+	return &resp, nil
+}
+
+func TestRouter_Use(t *testing.T) {
+	router := NewRouter()
+	router.Use(tagMiddleware("first"), tagMiddleware("second"))
+	router.RegisterRoute("users", pathRoute{}, tagMiddleware("route"))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	w := routeRecorder(router, req)
+
+	if w.Body.String() != "users  first,second,route" {
+		t.Fatalf("expected middleware to see the request in order, got %q", w.Body.String())
+	}
+
+	if order := strings.Join(w.Header().Values("X-Order"), ","); order != "route,second,first" {
+		t.Fatalf("expected middleware to see the response in reverse order, got %s", order)
+	}
+}
+
+func TestRouter_MiddlewareShortCircuit(t *testing.T) {
+	router := NewRouter()
+	router.RegisterRoute("users", pathRoute{}, denyMiddleware)
+	router.RegisterPattern(http.MethodGet, "/posts/{id}", pathRoute{}, denyMiddleware)
+
+	for _, rawUrl := range []string{"https://test.org/users/", "https://test.org/posts/1"} {
+		req, _ := http.NewRequest(http.MethodGet, rawUrl, nil)
+		if w := routeRecorder(router, req); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected http.StatusUnauthorized, got %d", rawUrl, w.Code)
+		}
+
+		req.Header.Set("Authorization", "yes")
+		if w := routeRecorder(router, req); w.Code != http.StatusOK {
+			t.Errorf("%s: expected http.StatusOK, got %d", rawUrl, w.Code)
+		}
+	}
+}
+
+func TestRouter_RequestResponder(t *testing.T) {
+	processor := new(redirectProcessor)
+
+	router := NewRouter()
+	router.RegisterRequestProcessor(http.MethodGet, processor)
+	router.RegisterRoute("new", pathRoute{})
+	router.RegisterRoute("old", RouteHandlerFunc(func(*RequestInfo) (*ResponseInfo, error) {
+		t.Error("expected the request to skip routing")
+		return nil, nil
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/old", nil)
+	w := routeRecorder(router, req)
+
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/new" {
+		t.Fatalf("expected a redirect to /new, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://test.org/new", nil)
+	if w := routeRecorder(router, req); w.Code != http.StatusOK {
+		t.Fatalf("expected http.StatusOK, got %d", w.Code)
+	}
+
+	if processor.processed {
+		t.Fatal("expected ProcessRequest to not be called on a RequestResponder")
+	}
+}
@@ -0,0 +1,111 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Mount mounts a router at the given prefix, so that it handles every request
+// under the prefix, as if it was a site of its own. The prefix is written in
+// the same way as a pattern (see RegisterPattern), but can only contain
+// literals, e.g., /api/v1.
+//
+// The mounted router sees the path of a request with the prefix removed:
+// a request to /api/v1/users/42 is routed to the endpoint users of the
+// mounted router, with the path 42. Requests go through the request
+// processors of this router first, and then through the ones of the mounted
+// router. The same goes for response processors.
+//
+// Only the endpoint tables, processors, and error renderers of the mounted
+// router are used. Errors from the mounted router are rendered by its own
+// error renderers first, see RegisterErrorRenderer. Its timeout, host
+// resolver, production mode, and draining are left to this router.
+func (r *Router) Mount(prefix string, router *Router) error {
+	if router == r {
+		return errors.New("a router can't be mounted in itself")
+	}
+
+	endpoint, segments, err := parsePattern(prefix)
+	if err != nil {
+		return err
+	}
+
+	if endpoint == EndpointRoot && len(segments) == 0 {
+		return fmt.Errorf("can't mount at %q, use RegisterRoute with EndpointDefault instead", prefix)
+	}
+
+	for _, s := range segments {
+		if s.kind != literalSegment {
+			return fmt.Errorf("mount prefix %q can only contain literals", prefix)
+		}
+	}
+
+	return r.registerPattern(&routePattern{
+		raw:      prefix,
+		segments: append(segments, patternSegment{kind: restSegment}),
+		handler:  router,
+		mount:    true,
+	}, endpoint)
+}
+
+// HandleRequest routes a request from another router through this router.
+// This lets a Router be used as a RouteHandler for a single endpoint:
+// the first section of the request's path becomes the endpoint, and
+// the request goes through this router's request processors before
+// reaching the handler of that endpoint, wrapped in this router's
+// middleware. See Mount.
+func (r *Router) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	resp, err := r.runRequestProcessors(req.request)
+	if err != nil {
+		return nil, r.mountedError(err)
+	}
+
+	if resp == nil {
+		info := req.rebase()
+
+		handler, _, err := r.getHandler(info)
+		if err != nil {
+			return nil, r.mountedError(err)
+		}
+
+		resp, err = Chain(handler, r.middleware...).HandleRequest(info)
+		if err != nil {
+			return nil, r.mountedError(err)
+		}
+
+		if resp == nil {
+			return nil, nil
+		}
+	}
+
+	resp.mounted = append([]*Router{r}, resp.mounted...)
+
+	return resp, nil
+}
+
+// mountedError is an error from a mounted router, which remembers the
+// routers it came through, so that its error response goes back through
+// them in the same way as any other response from them would.
+type mountedError struct {
+	err     error
+	mounted []*Router
+}
+
+func (e *mountedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *mountedError) Unwrap() error {
+	return e.err
+}
+
+// mountedError adds this router to the routers that the error came through.
+func (r *Router) mountedError(err error) error {
+	var mounted *mountedError
+	if errors.As(err, &mounted) {
+		mounted.mounted = append([]*Router{r}, mounted.mounted...)
+		return err
+	}
+
+	return &mountedError{err, []*Router{r}}
+}
@@ -0,0 +1,110 @@
+package routing
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// orderProcessor records the order that processors run in,
+// through the X-Order header of both the request and the response.
+type orderProcessor struct {
+	name string
+}
+
+func (o *orderProcessor) ProcessRequest(req *http.Request) error {
+	req.Header.Add("X-Order", o.name)
+	return nil
+}
+
+func (o *orderProcessor) ProcessResponse(resp *ResponseInfo) error {
+	resp.Headers.Add("X-Order", o.name)
+	return nil
+}
+
+// pathRoute responds with the endpoint and path it was given,
+// and the order of the request processors.
+type pathRoute struct{}
+
+func (pathRoute) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	body := req.RequestEndpoint() + " " + strings.Join(req.Path, "/") + " " + strings.Join(req.Headers().Values("X-Order"), ",")
+	resp := CreateResponseInfo(http.StatusOK, make(http.Header), Text, "users", bytes.NewBufferString(body))
+
+	return &resp, nil
+}
+
+func TestRouter_Mount(t *testing.T) {
+	inner := NewRouter()
+	inner.RegisterRoute("users", pathRoute{})
+	inner.RegisterRequestProcessor(http.MethodGet, &orderProcessor{"inner"})
+	inner.RegisterResponseProcessor(Text, "users", &orderProcessor{"inner"})
+
+	child := NewRouter()
+	child.RegisterRoute("users", pathRoute{})
+	child.RegisterRequestProcessor(http.MethodGet, &orderProcessor{"child"})
+	child.RegisterResponseProcessor(Text, "users", &orderProcessor{"child"})
+
+	if err := child.Mount("/inner", inner); err != nil {
+		t.Fatal(err)
+	}
+
+	parent := NewRouter()
+	parent.RegisterRequestProcessor(http.MethodGet, &orderProcessor{"parent"})
+	parent.RegisterResponseProcessor(Text, "users", &orderProcessor{"parent"})
+
+	if err := parent.Mount("/api/v1", child); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url   string
+		body  string
+		order string
+	}{
+		{"https://test.org/api/v1/users/42", "users 42 parent,child", "parent,child"},
+		{"https://test.org/api/v1/inner/users", "users  parent,child,inner", "parent,child,inner"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		w := routeRecorder(parent, req)
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected %q, got %q", test.url, test.body, w.Body.String())
+		}
+
+		if order := strings.Join(w.Header().Values("X-Order"), ","); order != test.order {
+			t.Errorf("%s: expected response processors in order %s, got %s", test.url, test.order, order)
+		}
+	}
+}
+
+func TestRouter_MountAsRoute(t *testing.T) {
+	child := NewRouter()
+	child.RegisterRoute(EndpointRoot, pathRoute{})
+
+	parent := NewRouter()
+	parent.RegisterRoute("child", child)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/child/", nil)
+	if w := routeRecorder(parent, req); w.Body.String() != "  " {
+		t.Fatalf("expected the child's root endpoint, got %q", w.Body.String())
+	}
+}
+
+func TestRouter_MountErrors(t *testing.T) {
+	router := NewRouter()
+	router.Mount("/api", NewRouter())
+
+	bad := []string{"/", "api", "/api/{id}", "/api"}
+	for _, prefix := range bad {
+		if err := router.Mount(prefix, NewRouter()); err == nil {
+			t.Errorf("expected prefix %q to be rejected", prefix)
+		}
+	}
+
+	if err := router.Mount("/self", router); err == nil {
+		t.Error("expected mounting a router in itself to be rejected")
+	}
+}
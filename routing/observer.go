@@ -0,0 +1,127 @@
+package routing
+
+import (
+	"net/http"
+	"time"
+)
+
+// Observer is notified whenever a request starts and ends a stage of the
+// router, e.g., to collect metrics, or to trace requests. Observers are called
+// synchronously from the stage itself, so they should return quickly.
+//
+// A stage that fails sends the request back to StagePostProcess with an
+// error response, so a single request can go through StagePostProcess more
+// than once. A stage that is skipped, as the request was cancelled before it
+// could run, never starts or ends.
+//
+// Observers must be safe for concurrent use, as requests are
+// routed concurrently.
+type Observer interface {
+	StageStart(event StageEvent)
+	StageEnd(event StageEvent)
+}
+
+// StageEvent describes a request that is starting or ending a stage. Fields
+// that aren't known yet at the time of the event are left as zero values.
+type StageEvent struct {
+	// Stage is the stage that is starting or ending.
+	Stage Stage
+
+	// Request is the request going through the router.
+	Request *http.Request
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Endpoint is the endpoint that the request was routed to. This is
+	// known once the request has been routed, see RequestInfo.RequestEndpoint.
+	Endpoint string
+
+	// Route is the key of the route whose handler the request was given
+	// to, which is either Endpoint, or EndpointDefault. Unlike Endpoint,
+	// which comes from the request, this is only ever a route that was
+	// registered to the router. Routed is whether a handler was found for
+	// the request at all, as Route isn't set otherwise.
+	Route  string
+	Routed bool
+
+	// Status is the HTTP status code of the response, and ResponseType is
+	// its type. These are known once there is a response.
+	Status       int
+	ResponseType ResponseType
+
+	// Received is when the router received the request.
+	Received time.Time
+
+	// Duration is how long the stage took. This is only set once the stage ends.
+	Duration time.Duration
+
+	// BytesSent is how many bytes of the body were sent to the client.
+	// This is only set once StageSend ends.
+	BytesSent int64
+
+	// Err is the error the stage failed with, if any. This is
+	// only set once the stage ends.
+	Err error
+}
+
+// RegisterObserver registers an Observer to every request
+// that goes through the router.
+func (r *Router) RegisterObserver(observer Observer) {
+	r.observers = append(r.observers, observer)
+}
+
+// event creates the StageEvent of the pipeline's current state.
+func (p *pipeline) event(stage Stage) StageEvent {
+	event := StageEvent{
+		Stage:    stage,
+		Request:  p.req,
+		Method:   p.req.Method,
+		Endpoint: p.endpoint,
+		Route:    p.route,
+		Routed:   p.routed,
+		Received: p.received,
+	}
+
+	if p.info != nil {
+		event.Status = p.info.Code()
+		event.ResponseType = p.info.ResponseType()
+
+		if event.Status == 0 {
+			event.Status = http.StatusOK
+		}
+	}
+
+	return event
+}
+
+// stageStart notifies every observer that the pipeline is starting the stage.
+func (r *Router) stageStart(p *pipeline, stage Stage) {
+	if len(r.observers) == 0 {
+		return
+	}
+
+	event := p.event(stage)
+	for _, o := range r.observers {
+		o.StageStart(event)
+	}
+}
+
+// stageEnd notifies every observer that the pipeline has ended the stage.
+func (r *Router) stageEnd(p *pipeline, stage Stage, start time.Time, err error) {
+	if len(r.observers) == 0 {
+		return
+	}
+
+	event := p.event(stage)
+	event.Duration = time.Since(start)
+	event.Err = err
+
+	if stage == StageSend {
+		event.BytesSent = p.sent
+	}
+
+	for _, o := range r.observers {
+		o.StageEnd(event)
+	}
+}
@@ -0,0 +1,129 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingObserver records every event it's given.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+	ends   []StageEvent
+}
+
+func (o *recordingObserver) StageStart(event StageEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = append(o.events, "start "+event.Stage.String())
+}
+
+func (o *recordingObserver) StageEnd(event StageEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.events = append(o.events, "end "+event.Stage.String())
+	o.ends = append(o.ends, event)
+}
+
+func TestRouter_RegisterObserver(t *testing.T) {
+	observer := new(recordingObserver)
+
+	router := NewRouter()
+	router.RegisterObserver(observer)
+	router.RegisterRoute("users", pathRoute{})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/42", nil)
+	w := routeRecorder(router, req)
+
+	expected := "start preProcess,end preProcess,start routing,end routing,start postProcess,end postProcess,start send,end send"
+	if events := strings.Join(observer.events, ","); events != expected {
+		t.Fatalf("expected events %s, got %s", expected, events)
+	}
+
+	send := observer.ends[len(observer.ends)-1]
+	if send.Endpoint != "users" || send.Method != http.MethodGet || send.Status != http.StatusOK || send.ResponseType != Text {
+		t.Fatalf("expected a GET to users with a 200 text response, got %+v", send)
+	}
+
+	if send.BytesSent != int64(w.Body.Len()) {
+		t.Fatalf("expected %d bytes sent, got %d", w.Body.Len(), send.BytesSent)
+	}
+
+	if send.Received.IsZero() || send.Err != nil {
+		t.Fatalf("expected the request to be received without errors, got %+v", send)
+	}
+	if !send.Routed || send.Route != "users" {
+		t.Fatalf("expected the request to be routed to users, got %+v", send)
+	}
+
+	observer.ends = nil
+	req, _ = http.NewRequest(http.MethodGet, "https://test.org/missing/", nil)
+	routeRecorder(router, req)
+
+	if send := observer.ends[len(observer.ends)-1]; send.Routed || send.Endpoint != "missing" {
+		t.Fatalf("expected missing to not be routed, got %+v", send)
+	}
+}
+
+func TestRouter_RegisterObserverRoute(t *testing.T) {
+	observer := new(recordingObserver)
+
+	router := NewRouter()
+	router.RegisterObserver(observer)
+	router.RegisterPattern(http.MethodGet, "/api/users/{id}", pathRoute{})
+	router.RegisterRoute(EndpointDefault, pathRoute{})
+
+	testValues := []struct {
+		url   string
+		route string
+	}{
+		{"https://test.org/api/users/42", "api"},
+		{"https://test.org/api/posts/42", EndpointDefault},
+		{"https://test.org/missing/", EndpointDefault},
+	}
+
+	for _, v := range testValues {
+		observer.ends = nil
+		req, _ := http.NewRequest(http.MethodGet, v.url, nil)
+		routeRecorder(router, req)
+
+		if send := observer.ends[len(observer.ends)-1]; !send.Routed || send.Route != v.route {
+			t.Fatalf("%s: expected the request to be routed to %s, got %+v", v.url, v.route, send)
+		}
+	}
+}
+
+func TestRouter_RegisterObserverError(t *testing.T) {
+	observer := new(recordingObserver)
+	cause := errors.New("route failed")
+
+	router := NewRouter()
+	router.RegisterObserver(observer)
+	router.RegisterRoute("users", &errorRoute{err: cause, onRoute: true})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	routeRecorder(router, req)
+
+	var routed, sent StageEvent
+	for _, e := range observer.ends {
+		switch e.Stage {
+		case StageRouting:
+			routed = e
+		case StageSend:
+			sent = e
+		}
+	}
+
+	if !errors.Is(routed.Err, cause) {
+		t.Fatalf("expected routing to end with %s, got %v", cause, routed.Err)
+	}
+
+	if sent.Status != http.StatusInternalServerError || sent.Endpoint != "users" {
+		t.Fatalf("expected a 500 from users to be sent, got %d from %s", sent.Status, sent.Endpoint)
+	}
+}
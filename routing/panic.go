@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicError is the error of a stage of the router that panicked. The
+// client receives an http.StatusInternalServerError, with the PanicError as
+// its internal cause (see HTTPError).
+type PanicError struct {
+	// Value is the value that was given to panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine that panicked,
+	// as formatted by runtime/debug.Stack.
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Unwrap returns the value given to panic, if it was an error.
+func (p *PanicError) Unwrap() error {
+	if err, ok := p.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// PanicHandler is called whenever a stage of the router panics, e.g., so
+// that the panic can be reported somewhere. It is called before the error
+// response is sent to the client.
+type PanicHandler func(req *http.Request, err *PanicError)
+
+// SetPanicHandler sets the PanicHandler of the router. If there's no
+// PanicHandler, panics are written to the router's error log, along
+// with their stack trace (see SetErrorLog).
+func (r *Router) SetPanicHandler(handler PanicHandler) {
+	r.panicHandler = handler
+}
+
+// recoverStage runs a single stage of the pipeline, recovering from any panic
+// in it. A panic closes the context with a PanicError, which is also returned.
+// A panic with http.ErrAbortHandler is the standard way of aborting a
+// response, so that is left for net/http to handle instead.
+func (r *Router) recoverStage(ctx *routingContext, req *http.Request, stage func() error) (err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+
+		if v == http.ErrAbortHandler {
+			ctx.CloseWithError(http.ErrAbortHandler)
+			panic(v)
+		}
+
+		panicErr := &PanicError{
+			Value: v,
+			Stack: debug.Stack(),
+		}
+
+		if r.panicHandler != nil {
+			r.panicHandler(req, panicErr)
+		} else {
+			r.logf("den: %s %s: %s\n%s", req.Method, req.URL, panicErr, panicErr.Stack)
+		}
+
+		ctx.CloseWithError(panicErr)
+		err = panicErr
+	}()
+
+	return stage()
+}
@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// panicRoute panics with its value at the given stage.
+type panicRoute struct {
+	value      any
+	onRequest  bool
+	onRoute    bool
+	onResponse bool
+}
+
+func (p *panicRoute) ProcessRequest(*http.Request) error {
+	if p.onRequest {
+		panic(p.value)
+	}
+
+	return nil
+}
+
+func (p *panicRoute) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	if p.onRoute {
+		panic(p.value)
+	}
+
+	return pathRoute{}.HandleRequest(req)
+}
+
+func (p *panicRoute) ProcessResponse(*ResponseInfo) error {
+	if p.onResponse {
+		panic(p.value)
+	}
+
+	return nil
+}
+
+// panicReader panics once it's read from.
+type panicReader struct{}
+
+func (panicReader) Read([]byte) (int, error) {
+	panic("reader panicked")
+}
+
+func TestRouter_RecoverPanic(t *testing.T) {
+	tests := []struct {
+		name  string
+		route *panicRoute
+	}{
+		{"request", &panicRoute{value: "boom", onRequest: true}},
+		{"route", &panicRoute{value: "boom", onRoute: true}},
+		{"response", &panicRoute{value: errors.New("boom"), onResponse: true}},
+	}
+
+	for _, test := range tests {
+		var reported *PanicError
+
+		router := NewRouter()
+		router.SetPanicHandler(func(req *http.Request, err *PanicError) {
+			reported = err
+		})
+
+		router.RegisterRequestProcessor(http.MethodGet, test.route)
+		router.RegisterRoute("users", test.route)
+		router.RegisterResponseProcessor(Text, "users", test.route)
+
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+		w := routeRecorder(router, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected http.StatusInternalServerError, got %d", test.name, w.Code)
+		}
+
+		if w.Body.String() != "Internal Server Error: panic: boom" {
+			t.Errorf("%s: expected the panic in the body, got %q", test.name, w.Body.String())
+		}
+
+		if reported == nil || !bytes.Contains(reported.Stack, []byte("panic_test.go")) {
+			t.Errorf("%s: expected the panic to be reported with its stack trace", test.name)
+		}
+	}
+}
+
+func TestRouter_RecoverPanicLogs(t *testing.T) {
+	var logs bytes.Buffer
+
+	router := NewRouter()
+	router.SetErrorLog(log.New(&logs, "", 0))
+	router.RegisterRoute("users", &panicRoute{value: "boom", onRoute: true})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	routeRecorder(router, req)
+
+	if !strings.Contains(logs.String(), "panic: boom") || !strings.Contains(logs.String(), "goroutine") {
+		t.Fatalf("expected the panic to be logged with its stack trace, got %q", logs.String())
+	}
+
+	logs.Reset()
+	router.SetProductionMode(true)
+	routeRecorder(router, req)
+
+	if n := strings.Count(logs.String(), "panic: boom"); n != 1 {
+		t.Fatalf("expected the panic to be logged once in production mode, got %d times: %q", n, logs.String())
+	}
+}
+
+func TestRouter_RecoverPanicWhileSending(t *testing.T) {
+	router := NewRouter()
+	router.SetErrorLog(log.New(io.Discard, "", 0))
+	router.RegisterRoute("users", &responseRoute{CreateResponseInfo(http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, Data, "users", panicReader{})})
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("expected http.ErrAbortHandler, got %v", v)
+		}
+	}()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	routeRecorder(router, req)
+}
+
+func TestRouter_RecoverPanicAbortHandler(t *testing.T) {
+	var logs bytes.Buffer
+
+	router := NewRouter()
+	router.SetErrorLog(log.New(&logs, "", 0))
+	router.RegisterRoute("users", &panicRoute{value: http.ErrAbortHandler, onRoute: true})
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("expected http.ErrAbortHandler, got %v", v)
+		}
+
+		if logs.Len() != 0 {
+			t.Fatalf("expected an aborted response to not be logged, got %q", logs.String())
+		}
+
+		// the request is still done with, even though it was aborted
+		if router.drain.active != 0 {
+			t.Fatalf("expected no active requests, got %d", router.drain.active)
+		}
+	}()
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/users/", nil)
+	routeRecorder(router, req)
+}
@@ -0,0 +1,334 @@
+package routing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type segmentKind int
+
+// these are ordered from most to least specific
+const (
+	literalSegment segmentKind = iota
+	paramSegment
+	restSegment
+)
+
+type patternSegment struct {
+	kind segmentKind
+	// value is either the literal, or the name of the parameter
+	value string
+}
+
+type routePattern struct {
+	// method is the HTTP method this pattern handles, or empty for any
+	method   string
+	raw      string
+	segments []patternSegment
+	handler  RouteHandler
+
+	// mount is whether this pattern is a mount point, see Mount
+	mount bool
+}
+
+// routeTable holds every pattern registered under a single endpoint.
+type routeTable struct {
+	patterns []*routePattern
+}
+
+// parsePattern parses a pattern into its endpoint, and the segments
+// that come after it.
+func parsePattern(pattern string) (string, []patternSegment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return "", nil, fmt.Errorf("pattern %q must start with /", pattern)
+	}
+
+	raw := strings.Split(strings.Trim(pattern, "/"), "/")
+	endpoint := raw[0]
+
+	if strings.ContainsAny(endpoint, "{}") {
+		return "", nil, fmt.Errorf("pattern %q: the endpoint must be a literal", pattern)
+	}
+
+	segments := make([]patternSegment, 0, len(raw)-1)
+	params := make(map[string]bool)
+
+	for i, s := range raw[1:] {
+		if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+			if s == "" || strings.ContainsAny(s, "{}") {
+				return "", nil, fmt.Errorf("pattern %q: bad segment %q", pattern, s)
+			}
+
+			segments = append(segments, patternSegment{literalSegment, s})
+			continue
+		}
+
+		name := s[1 : len(s)-1]
+		kind := paramSegment
+
+		if strings.HasSuffix(name, "...") {
+			if i != len(raw)-2 {
+				return "", nil, fmt.Errorf("pattern %q: %s must be the last segment", pattern, s)
+			}
+
+			name = strings.TrimSuffix(name, "...")
+			kind = restSegment
+		}
+
+		if name == "" || strings.ContainsAny(name, "{}/") {
+			return "", nil, fmt.Errorf("pattern %q: bad parameter name %q", pattern, name)
+		}
+
+		if params[name] {
+			return "", nil, fmt.Errorf("pattern %q: parameter %q given twice", pattern, name)
+		}
+
+		params[name] = true
+		segments = append(segments, patternSegment{kind, name})
+	}
+
+	return endpoint, segments, nil
+}
+
+// match matches the path (without the endpoint) against the pattern,
+// and returns the parameters found along the way.
+func (p *routePattern) match(path []string) (map[string]string, bool) {
+	params := make(map[string]string)
+
+	for i, s := range p.segments {
+		if s.kind == restSegment {
+			rest := make([]string, len(path)-i)
+			for j, segment := range path[i:] {
+				rest[j] = unescapeSegment(segment)
+			}
+
+			// the rest of a mount point has no name, see Mount
+			if s.value != "" {
+				params[s.value] = strings.Join(rest, "/")
+			}
+
+			return params, true
+		}
+
+		if i >= len(path) {
+			return nil, false
+		}
+
+		switch s.kind {
+		case literalSegment:
+			if unescapeSegment(path[i]) != s.value {
+				return nil, false
+			}
+		case paramSegment:
+			params[s.value] = unescapeSegment(path[i])
+		}
+	}
+
+	return params, len(path) == len(p.segments)
+}
+
+// moreSpecific reports whether the pattern is more specific than the other.
+func (p *routePattern) moreSpecific(other *routePattern) bool {
+	for i := 0; i < len(p.segments) && i < len(other.segments); i++ {
+		if p.segments[i].kind != other.segments[i].kind {
+			return p.segments[i].kind < other.segments[i].kind
+		}
+	}
+
+	if len(p.segments) != len(other.segments) {
+		return len(p.segments) > len(other.segments)
+	}
+
+	// a specific method beats any method
+	return p.method != "" && other.method == ""
+}
+
+// handles is whether the pattern handles the method.
+func (p *routePattern) handles(method string) bool {
+	return p.method == "" || p.method == method || p.method == http.MethodGet && method == http.MethodHead
+}
+
+// lookup finds the most specific pattern for the given method and path.
+// If patterns match the path, but none of them handle the method, the
+// methods that are allowed are returned instead. GET patterns match HEAD
+// as well, though a HEAD pattern beats them.
+func (t *routeTable) lookup(method string, path []string) (*routePattern, map[string]string, []string) {
+	var best *routePattern
+	var bestParams map[string]string
+	allowed := make(map[string]bool)
+
+	for _, p := range t.patterns {
+		params, ok := p.match(path)
+		if !ok {
+			continue
+		}
+
+		if !p.handles(method) {
+			allowed[p.method] = true
+			continue
+		}
+
+		if best == nil || p.moreSpecific(best) || !best.moreSpecific(p) && p.method == method && best.method != method {
+			best, bestParams = p, params
+		}
+	}
+
+	if best != nil {
+		return best, bestParams, nil
+	}
+
+	// whatever handles GET handles HEAD too
+	if allowed[http.MethodGet] {
+		allowed[http.MethodHead] = true
+	}
+
+	methods := make([]string, 0, len(allowed))
+	for m := range allowed {
+		methods = append(methods, m)
+	}
+
+	sort.Strings(methods)
+	return nil, nil, methods
+}
+
+// RegisterPattern registers a route handler to a pattern, for the given HTTP
+// method. If the method is empty, the handler handles every method. As with
+// net/http, a GET pattern also handles HEAD requests, unless there is a HEAD
+// pattern of its own. Any middleware given only wraps this pattern, see Chain.
+//
+// A pattern matches the endpoint and the path of a request, for example:
+//
+//	/api/users/{id}
+//	/static/{file...}
+//
+// The first segment of a pattern is always the endpoint, and has to be
+// a literal (or empty, for EndpointRoot). Every other segment is either:
+//
+//	literal     matches a segment exactly
+//	{name}      matches any single segment, as the parameter name
+//	{name...}   matches the rest of the path, including nothing at all, as
+//	            the parameter name (this can only be the last segment)
+//
+// Parameters are available to the handler through RequestInfo.Param. When
+// more than one pattern matches a request, the most specific one wins:
+// literals beat parameters, which beat the rest of the path.
+//
+// Patterns are tried before any route registered through RegisterRoute to the
+// same endpoint. If a pattern matches the path of a request, but not its
+// method, the router responds with http.StatusMethodNotAllowed instead.
+func (r *Router) RegisterPattern(method string, pattern string, handler RouteHandler, middleware ...Middleware) error {
+	endpoint, segments, err := parsePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	return r.registerPattern(&routePattern{
+		method:   strings.ToUpper(method),
+		raw:      pattern,
+		segments: segments,
+		handler:  Chain(handler, middleware...),
+	}, endpoint)
+}
+
+func (r *Router) registerPattern(pattern *routePattern, endpoint string) error {
+	if r.patterns == nil {
+		r.patterns = make(map[string]*routeTable)
+	}
+
+	table, ok := r.patterns[endpoint]
+	if !ok {
+		table = new(routeTable)
+		r.patterns[endpoint] = table
+	}
+
+	for _, p := range table.patterns {
+		if p.method == pattern.method && samePattern(p.segments, pattern.segments) {
+			return fmt.Errorf("pattern %s %s conflicts with %s %s", pattern.method, pattern.raw, p.method, p.raw)
+		}
+	}
+
+	table.patterns = append(table.patterns, pattern)
+	return nil
+}
+
+// samePattern reports whether both patterns would always match the same paths.
+func samePattern(a, b []patternSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].kind != b[i].kind || (a[i].kind == literalSegment && a[i].value != b[i].value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// errNoPattern is returned by getPatternHandler when no pattern matches.
+var errNoPattern = errors.New("no pattern matches the request")
+
+// getPatternHandler finds the handler for the request from the patterns of
+// its endpoint, filling in the parameters of the request.
+func (r *Router) getPatternHandler(info *RequestInfo) (RouteHandler, error) {
+	table, ok := r.patterns[info.requestEndpoint]
+	if !ok {
+		return nil, errNoPattern
+	}
+
+	pattern, params, allowed := table.lookup(info.Method(), info.Path)
+	if pattern != nil {
+		// params of a router this one is mounted in are kept
+		for k, v := range info.params {
+			if _, ok := params[k]; !ok {
+				params[k] = v
+			}
+		}
+
+		info.params = params
+
+		if pattern.mount {
+			info.Path = info.Path[len(pattern.segments)-1:]
+		}
+
+		return pattern.handler, nil
+	}
+
+	if len(allowed) == 0 {
+		return nil, errNoPattern
+	}
+
+	return methodNotAllowed(allowed), nil
+}
+
+// methodNotAllowed responds with http.StatusMethodNotAllowed, and the
+// methods that are allowed in the Allow header.
+type methodNotAllowed []string
+
+func (m methodNotAllowed) HandleRequest(*RequestInfo) (*ResponseInfo, error) {
+	resp := CreateResponseInfo(
+		http.StatusMethodNotAllowed,
+		http.Header{"Allow": {strings.Join(m, ", ")}},
+		Text,
+		EndpointError,
+		bytes.NewBufferString("method not allowed"),
+	)
+
+	return &resp, nil
+}
+
+// unescapeSegment unescapes a segment of an escaped path, or returns it
+// as-is if it isn't validly escaped.
+func unescapeSegment(segment string) string {
+	if s, err := url.PathUnescape(segment); err == nil {
+		return s
+	}
+
+	return segment
+}
@@ -0,0 +1,160 @@
+package routing
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// paramRoute responds with its name, and the parameters of the request.
+type paramRoute struct {
+	name string
+}
+
+func (p *paramRoute) HandleRequest(req *RequestInfo) (*ResponseInfo, error) {
+	params := req.Params()
+	keys := make([]string, 0, len(params))
+	for _, k := range []string{"id", "file"} {
+		if v, ok := params[k]; ok {
+			keys = append(keys, k+"="+v)
+		}
+	}
+
+	resp := CreateResponseInfo(
+		http.StatusOK,
+		nil,
+		Text,
+		req.RequestEndpoint(),
+		bytes.NewBufferString(p.name+" "+strings.Join(keys, ",")),
+	)
+
+	return &resp, nil
+}
+
+func routeMethod(router *Router, method string, rawUrl string) *httptest.ResponseRecorder {
+	testUrl, _ := url.Parse(rawUrl)
+	return routeRecorder(router, &http.Request{
+		Method: method,
+		URL:    testUrl,
+	})
+}
+
+func routeRecorder(router *Router, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.RouteRequest(w, req)
+
+	return w
+}
+
+func TestRouter_RegisterPattern(t *testing.T) {
+	router := NewRouter()
+
+	patterns := []struct {
+		method  string
+		pattern string
+	}{
+		{http.MethodGet, "/api/users/{id}"},
+		{http.MethodGet, "/api/users/me"},
+		{http.MethodPost, "/api/users"},
+		{"", "/api/users/{id}/files/{file...}"},
+	}
+
+	for _, p := range patterns {
+		if err := router.RegisterPattern(p.method, p.pattern, &paramRoute{p.method + " " + p.pattern}); err != nil {
+			t.Fatalf("could not register %s %s: %s", p.method, p.pattern, err)
+		}
+	}
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{http.MethodGet, "https://test.org/api/users/42", "GET /api/users/{id} id=42"},
+		{http.MethodGet, "https://test.org/api/users/me", "GET /api/users/me "},
+		{http.MethodGet, "https://test.org/api/users/a%2Fb", "GET /api/users/{id} id=a/b"},
+		{http.MethodPost, "https://test.org/api/users", "POST /api/users "},
+		{http.MethodDelete, "https://test.org/api/users/42/files/a/b.txt", " /api/users/{id}/files/{file...} id=42,file=a/b.txt"},
+		{http.MethodGet, "https://test.org/api/users/42/files", " /api/users/{id}/files/{file...} id=42,file="},
+	}
+
+	for _, test := range tests {
+		w := routeMethod(router, test.method, test.url)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s %s: expected http.StatusOK, got %d", test.method, test.url, w.Code)
+			continue
+		}
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.url, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_RegisterPatternMethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+	router.RegisterPattern(http.MethodGet, "/api/users/{id}", &paramRoute{"get"})
+	router.RegisterPattern(http.MethodDelete, "/api/users/{id}", &paramRoute{"delete"})
+
+	w := routeMethod(router, http.MethodPost, "https://test.org/api/users/42")
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected http.StatusMethodNotAllowed, got %d", w.Code)
+	}
+
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD" {
+		t.Fatalf("expected Allow to be DELETE, GET, HEAD, got %q", allow)
+	}
+}
+
+func TestRouter_RegisterPatternHead(t *testing.T) {
+	router := NewRouter()
+	router.RegisterPattern(http.MethodGet, "/api/users/{id}", &paramRoute{"get"})
+	router.RegisterPattern(http.MethodGet, "/api/posts/{id}", &paramRoute{"get"})
+	router.RegisterPattern(http.MethodHead, "/api/posts/{id}", &paramRoute{"head"})
+
+	if w := routeMethod(router, http.MethodHead, "https://test.org/api/users/1"); w.Code != http.StatusOK || w.Body.String() != "get id=1" {
+		t.Fatalf("expected the GET pattern to handle HEAD, got %d and %q", w.Code, w.Body.String())
+	}
+
+	if w := routeMethod(router, http.MethodHead, "https://test.org/api/posts/1"); w.Code != http.StatusOK || w.Body.String() != "head id=1" {
+		t.Fatalf("expected the HEAD pattern to handle HEAD, got %d and %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRouter_RegisterPatternFallsBack(t *testing.T) {
+	router := NewRouter()
+	router.RegisterPattern(http.MethodGet, "/api/users/{id}", &paramRoute{"pattern"})
+	router.RegisterRoute("api", &paramRoute{"route"})
+
+	w := routeMethod(router, http.MethodGet, "https://test.org/api/posts/1")
+
+	if w.Body.String() != "route " {
+		t.Fatalf("expected the route to handle the request, got %q", w.Body.String())
+	}
+}
+
+func TestRouter_RegisterPatternErrors(t *testing.T) {
+	router := NewRouter()
+	router.RegisterPattern(http.MethodGet, "/api/users/{id}", &paramRoute{})
+
+	bad := []string{
+		"api/users",
+		"/{endpoint}/users",
+		"/api/{file...}/users",
+		"/api/{}",
+		"/api/{id}/{id}",
+		"/api//users",
+		"/api/users/{name}",
+	}
+
+	for _, pattern := range bad {
+		if err := router.RegisterPattern(http.MethodGet, pattern, &paramRoute{}); err == nil {
+			t.Errorf("expected pattern %q to be rejected", pattern)
+		}
+	}
+}
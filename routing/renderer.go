@@ -0,0 +1,270 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrorRenderer renders the body of an error response, e.g., as a branded
+// HTML page. It is given the request, the error response (whose Body and
+// Headers it can replace), and the error that the response is for.
+//
+// Error responses are any responses from the EndpointError endpoint, which
+// includes every error from the router itself (see HTTPError), and any
+// response created through CreateGenericErrorResponse.
+type ErrorRenderer interface {
+	RenderError(req *http.Request, resp *ResponseInfo, err *HTTPError) error
+}
+
+// ErrorRendererFunc lets an ordinary function be used as an ErrorRenderer.
+type ErrorRendererFunc func(req *http.Request, resp *ResponseInfo, err *HTTPError) error
+
+func (f ErrorRendererFunc) RenderError(req *http.Request, resp *ResponseInfo, err *HTTPError) error {
+	return f(req, resp, err)
+}
+
+// AnyStatus registers an ErrorRenderer for every status code that doesn't
+// have a renderer of its own, see RegisterErrorRenderer.
+const AnyStatus = 0
+
+// RegisterErrorRenderer registers an ErrorRenderer for error responses with
+// the given status code (or AnyStatus), that renders them as the given
+// content type. The content type is negotiated with the Accept header of the
+// request, so renderers can be registered for the same status code with
+// different content types, e.g., text/html for browsers, and application/json
+// for everything else. An empty content type is used when no other content
+// type is acceptable to the client.
+//
+// Renderers for the exact status code are tried before renderers for
+// AnyStatus. If no renderer is acceptable, and the client explicitly accepts
+// JSON, the error is rendered as JSON problem details (RFC 7807). Otherwise,
+// the error response is left as plain text.
+//
+// An error from a mounted router (see Mount) is rendered by the innermost
+// router that has an acceptable renderer for it, so a mounted router can
+// render its errors differently from the router it is mounted in.
+//
+// Error renderers run before any response processor that was registered to
+// EndpointError, so those processors see the rendered response.
+func (r *Router) RegisterErrorRenderer(code int, contentType string, renderer ErrorRenderer) {
+	if r.errorRenderers == nil {
+		r.errorRenderers = make(map[int]map[string]ErrorRenderer)
+	}
+
+	if r.errorRenderers[code] == nil {
+		r.errorRenderers[code] = make(map[string]ErrorRenderer)
+	}
+
+	r.errorRenderers[code][contentType] = renderer
+}
+
+// getErrorRenderer gets the renderer for an error with the given status code,
+// and the content type it renders, based on what the client accepts. The
+// routers are tried from the last to the first, i.e., from the inside out.
+func getErrorRenderer(routers []*Router, code int, accept string) (ErrorRenderer, string, bool) {
+	ranges := parseAccept(accept)
+
+	for i := len(routers) - 1; i >= 0; i-- {
+		if renderer, contentType, ok := routers[i].getErrorRenderer(code, ranges); ok {
+			return renderer, contentType, true
+		}
+	}
+
+	if contentType, ok := negotiate(ranges, problemTypes, true); ok {
+		return problemTypes[contentType], contentType, true
+	}
+
+	return nil, "", false
+}
+
+// getErrorRenderer gets the renderer of this router for an error with the
+// given status code, and the content type it renders.
+func (r *Router) getErrorRenderer(code int, ranges []acceptRange) (ErrorRenderer, string, bool) {
+	for _, c := range []int{code, AnyStatus} {
+		if contentType, ok := negotiate(ranges, r.errorRenderers[c], false); ok {
+			return r.errorRenderers[c][contentType], contentType, true
+		}
+	}
+
+	for _, c := range []int{code, AnyStatus} {
+		if renderer, ok := r.errorRenderers[c][""]; ok {
+			return renderer, "", true
+		}
+	}
+
+	return nil, "", false
+}
+
+// problemTypes are the content types that the default
+// renderer can render JSON problem details as.
+var problemTypes = map[string]ErrorRenderer{
+	"application/problem+json": ErrorRendererFunc(renderProblem),
+	"application/json":         ErrorRendererFunc(renderProblem),
+}
+
+// problem is the JSON problem details object, as defined in RFC 7807.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// renderProblem renders an error as JSON problem details.
+func renderProblem(req *http.Request, resp *ResponseInfo, err *HTTPError) error {
+	p := problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(err.Code),
+		Status: err.Code,
+		Detail: err.Message,
+	}
+
+	if err.Err != nil && p.Detail != "" {
+		p.Detail += ": " + err.Err.Error()
+	} else if err.Err != nil {
+		p.Detail = err.Err.Error()
+	}
+
+	if req.URL != nil {
+		p.Instance = req.URL.Path
+	}
+
+	body, jsonErr := json.Marshal(p)
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	resp.Body = bytes.NewReader(body)
+	return nil
+}
+
+// renderError renders an error response through the error renderers of
+// the routers it came through, see getErrorRenderer.
+func renderError(routers []*Router, resp *ResponseInfo) error {
+	if resp.rendered || resp.request == nil {
+		return nil
+	}
+
+	err := resp.err
+	if err == nil {
+		err = NewHTTPError(resp.code, "", nil)
+	}
+
+	renderer, contentType, ok := getErrorRenderer(routers, err.Code, resp.request.Header.Get("Accept"))
+	if !ok {
+		return nil
+	}
+
+	// the headers may be shared with the handler that made the response
+	resp.Headers = resp.Headers.Clone()
+	if resp.Headers == nil {
+		resp.Headers = make(http.Header)
+	}
+
+	if contentType != "" {
+		resp.Headers.Set("Content-Type", contentType)
+	}
+
+	// the old body's length no longer applies
+	resp.Headers.Del("Content-Length")
+	resp.rendered = true
+
+	return renderer.RenderError(resp.request, resp, err)
+}
+
+// acceptRange is a single media range from an Accept header.
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an Accept header into its media ranges.
+// An empty header accepts everything.
+func parseAccept(accept string) []acceptRange {
+	if strings.TrimSpace(accept) == "" {
+		return []acceptRange{{"*/*", 1}}
+	}
+
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType, q})
+	}
+
+	return ranges
+}
+
+// quality gets how acceptable the content type is to the client, and how
+// specific the range that matched it was (2 for an exact match, 1 for
+// type/*, and 0 for */*).
+func quality(ranges []acceptRange, contentType string) (float64, int) {
+	q, specificity := 0.0, -1
+	mainType := strings.SplitN(contentType, "/", 2)[0]
+
+	for _, r := range ranges {
+		s := -1
+
+		switch {
+		case r.mediaType == contentType:
+			s = 2
+		case r.mediaType == mainType+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		}
+
+		// the most specific range decides the quality
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q, specificity
+}
+
+// negotiate picks the content type that the client prefers the most. If
+// explicit is set, content types that are only acceptable through */* are
+// never picked.
+func negotiate(ranges []acceptRange, offers map[string]ErrorRenderer, explicit bool) (string, bool) {
+	types := make([]string, 0, len(offers))
+	for contentType := range offers {
+		if contentType != "" {
+			types = append(types, contentType)
+		}
+	}
+
+	sort.Strings(types)
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+
+	for _, contentType := range types {
+		q, specificity := quality(ranges, contentType)
+		if q <= 0 || (explicit && specificity < 1) {
+			continue
+		}
+
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = contentType, q, specificity
+		}
+	}
+
+	return best, best != ""
+}
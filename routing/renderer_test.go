@@ -0,0 +1,159 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// pageRenderer renders errors as a page with its name.
+func pageRenderer(name string) ErrorRenderer {
+	return ErrorRendererFunc(func(req *http.Request, resp *ResponseInfo, err *HTTPError) error {
+		resp.Body = bytes.NewBufferString(name + " " + err.PublicMessage())
+		return nil
+	})
+}
+
+func TestRouter_RegisterErrorRenderer(t *testing.T) {
+	router := NewRouter()
+	router.RegisterErrorRenderer(http.StatusNotFound, "text/html", pageRenderer("html 404"))
+	router.RegisterErrorRenderer(AnyStatus, "text/html", pageRenderer("html"))
+	router.RegisterErrorRenderer(AnyStatus, "text/csv", pageRenderer("csv"))
+	router.RegisterErrorRenderer(AnyStatus, "", pageRenderer("fallback"))
+	router.RegisterRoute("fail", &errorRoute{err: NewHTTPError(http.StatusForbidden, "", nil), onRoute: true})
+
+	tests := []struct {
+		path        string
+		accept      string
+		body        string
+		contentType string
+	}{
+		{"/missing", "text/html,application/xhtml+xml,*/*;q=0.8", "html 404 Not Found", "text/html"},
+		{"/fail", "text/html,application/xhtml+xml,*/*;q=0.8", "html Forbidden", "text/html"},
+		{"/fail", "text/csv;q=0.9, text/html;q=0.5", "csv Forbidden", "text/csv"},
+		{"/fail", "text/*;q=0.5, text/csv;q=0.1", "html Forbidden", "text/html"},
+		{"/fail", "image/png", "fallback Forbidden", "text/plain; charset=utf-8"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org"+test.path, nil)
+		req.Header.Set("Accept", test.accept)
+		w := routeRecorder(router, req)
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s with %s: expected %q, got %q", test.path, test.accept, test.body, w.Body.String())
+		}
+
+		if w.Header().Get("Content-Type") != test.contentType {
+			t.Errorf("%s with %s: expected %s, got %s", test.path, test.accept, test.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestRouter_ErrorRendererMounted(t *testing.T) {
+	inner := NewRouter()
+	inner.RegisterErrorRenderer(AnyStatus, "", pageRenderer("inner"))
+	inner.RegisterRoute("fail", &errorRoute{err: NewHTTPError(http.StatusForbidden, "", nil), onRoute: true})
+
+	child := NewRouter()
+	child.RegisterErrorRenderer(AnyStatus, "", pageRenderer("child"))
+	child.Mount("/inner", inner)
+
+	parent := NewRouter()
+	parent.RegisterErrorRenderer(http.StatusNotFound, "", pageRenderer("parent"))
+	parent.Mount("/api", child)
+	parent.Mount("/plain", NewRouter())
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/missing", "parent Not Found"},
+		{"/api/missing", "child Not Found"},
+		{"/api/inner/missing", "inner Not Found"},
+		{"/api/inner/fail", "inner Forbidden"},
+		{"/plain/missing", "parent Not Found"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "https://test.org"+test.path, nil)
+		w := routeRecorder(parent, req)
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected %q, got %q", test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_ErrorRendererProblemDetails(t *testing.T) {
+	router := NewRouter()
+	router.RegisterRoute("fail", &errorRoute{err: NewHTTPError(http.StatusConflict, "already exists", errors.New("duplicate key")), onRoute: true})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/fail", nil)
+	req.Header.Set("Accept", "application/problem+json, application/json;q=0.9")
+	w := routeRecorder(router, req)
+
+	if w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected application/problem+json, got %s", w.Header().Get("Content-Type"))
+	}
+
+	var p problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := problem{"about:blank", "Conflict", http.StatusConflict, "already exists: duplicate key", "/fail"}
+	if p != expected {
+		t.Fatalf("expected %+v, got %+v", expected, p)
+	}
+
+	// clients that don't explicitly accept JSON get plain text
+	req.Header.Set("Accept", "*/*")
+	if w := routeRecorder(router, req); w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Fatalf("expected plain text, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRouter_ErrorRendererProduction(t *testing.T) {
+	router := NewRouter()
+	router.SetProductionMode(true)
+	router.SetErrorLog(nopLogger())
+	router.RegisterRoute("fail", &errorRoute{err: errors.New("secret"), onRoute: true})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/fail", nil)
+	req.Header.Set("Accept", "application/json")
+	w := routeRecorder(router, req)
+
+	var p problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Status != http.StatusInternalServerError || p.Detail != "" {
+		t.Fatalf("expected a 500 without details, got %+v", p)
+	}
+}
+
+func TestRouter_ErrorRendererSharedHeaders(t *testing.T) {
+	shared := http.Header{"Content-Length": {"7"}}
+
+	router := NewRouter()
+	router.RegisterErrorRenderer(AnyStatus, "text/html", pageRenderer("html"))
+	router.RegisterRoute("gone", &responseRoute{CreateResponseInfo(
+		http.StatusGone, shared, Text, EndpointError, bytes.NewBufferString("gone..."),
+	)})
+
+	req, _ := http.NewRequest(http.MethodGet, "https://test.org/gone", nil)
+	req.Header.Set("Accept", "text/html")
+	w := routeRecorder(router, req)
+
+	if w.Body.String() != "html Gone" || w.Header().Get("Content-Type") != "text/html" {
+		t.Fatalf("expected the rendered page, got %q as %s", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+
+	if len(shared) != 1 || shared.Get("Content-Length") != "7" {
+		t.Fatalf("expected the shared headers to be left alone, got %v", shared)
+	}
+}
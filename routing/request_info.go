@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -17,31 +18,84 @@ type RequestInfo struct {
 	// Raw request for this struct. Expose fields as necessary.
 	request *http.Request
 
+	// Context of the request. When routed through a Router, this is
+	// cancelled once the client goes away, or the router times out.
+	ctx context.Context
+
 	// Request endpoint that this request is fetching information from. This is either
 	// from the subdomain, or from the first section of the path given in the request's URI.
 	requestEndpoint string
 
 	Path []string // should be its own API because golang doesn't have a neat path thing but oh well?
 
+	// Parameters of the pattern that matched this request, if any.
+	params map[string]string
+
 	// Query of the request, from the URL.
 	Query url.Values
 }
 
 // NewRequestInfo creates a new RequestInfo based on the request passed into it.
+// The endpoint is found in the same way as a Router without a HostResolver.
 func NewRequestInfo(req *http.Request) *RequestInfo {
+	info, _ := newRequestInfo(req, defaultHostResolver)
+	return info
+}
+
+// newRequestInfo creates a new RequestInfo, finding the endpoint through the
+// given resolver. The router whose endpoint tables the request should be routed
+// to is returned as well, or nil if it's the router the resolver belongs to.
+func newRequestInfo(req *http.Request, resolver *HostResolver) (*RequestInfo, *Router) {
 	info := RequestInfo{
 		request: req,
+		ctx:     req.Context(),
 	}
 
-	info.getInfoFromUrl(req.URL)
+	table, subdomain, ok := resolver.resolve(requestHost(req))
+	info.getInfoFromUrl(req.URL, subdomain, ok)
 
-	return &info
+	return &info, table
 }
 
 func (i *RequestInfo) RequestEndpoint() string {
 	return i.requestEndpoint
 }
 
+// Context returns the context of the request. Anything that could take a
+// while (e.g., database calls) should be given this context, so that it
+// can be aborted once the request is no longer needed.
+func (i *RequestInfo) Context() context.Context {
+	if i.ctx == nil {
+		return context.Background()
+	}
+
+	return i.ctx
+}
+
+// Attributes returns the Attributes of this request, shared with every
+// other stage of the pipeline. This is nil if the request wasn't routed
+// through a Router.
+func (i *RequestInfo) Attributes() *Attributes {
+	return AttributesFrom(i.Context())
+}
+
+// Param returns the value of the named parameter from the pattern that
+// matched this request, or an empty string if there is no such parameter.
+// See Router.RegisterPattern.
+func (i *RequestInfo) Param(name string) string {
+	return i.params[name]
+}
+
+// Params returns every parameter from the pattern that matched this request.
+func (i *RequestInfo) Params() map[string]string {
+	params := make(map[string]string, len(i.params))
+	for k, v := range i.params {
+		params[k] = v
+	}
+
+	return params
+}
+
 // Method exposes the HTTP request method to the caller.
 func (i *RequestInfo) Method() string {
 	return i.request.Method
@@ -58,21 +112,46 @@ func (i *RequestInfo) Headers() http.Header {
 	return i.request.Header
 }
 
+// URL exposes the URL of the HTTP request to the caller, as it was
+// requested, before it was split into the endpoint and the path.
+func (i *RequestInfo) URL() *url.URL {
+	return i.request.URL
+}
+
+// rebase creates the RequestInfo of a router mounted at this request's
+// endpoint, where the first section of the remaining path is the endpoint.
+func (i *RequestInfo) rebase() *RequestInfo {
+	info := *i
+	info.requestEndpoint = EndpointRoot
+
+	if len(i.Path) > 0 {
+		info.requestEndpoint = i.Path[0]
+		info.Path = i.Path[1:]
+	}
+
+	return &info
+}
+
 // getInfoFromUrl gets the endpoint and the path from this URL, and fills in
-// the respective fields for the RequestInfo struct given. Since URLs are complex,
-// this only fetches the first section of the subdomain from the hostname, and
-// if that isn't a valid subdomain (to be implemented: blacklist), it will instead
-// use the first section of the path given to it.
-func (i *RequestInfo) getInfoFromUrl(rawUrl *url.URL) {
-	h := strings.Split(rawUrl.Hostname(), ".")
+// the respective fields for the RequestInfo struct given. If the host had a
+// subdomain that should be used as the endpoint (see HostResolver), the whole
+// path is kept as-is, otherwise the first section of the path is the endpoint.
+func (i *RequestInfo) getInfoFromUrl(rawUrl *url.URL, subdomain string, hasSubdomain bool) {
 	p := strings.Split(strings.Trim(rawUrl.EscapedPath(), "/"), "/")
+	if p[0] == "" {
+		p = p[:0]
+	}
+
+	i.requestEndpoint = subdomain
+	i.Path = p
+
+	if !hasSubdomain {
+		i.requestEndpoint = EndpointRoot
 
-	if len(h) <= 2 {
-		i.requestEndpoint = p[0]
-		i.Path = p[1:]
-	} else {
-		i.requestEndpoint = h[0]
-		i.Path = p
+		if len(p) > 0 {
+			i.requestEndpoint = p[0]
+			i.Path = p[1:]
+		}
 	}
 
 	i.Query = rawUrl.Query()
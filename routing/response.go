@@ -2,8 +2,15 @@ package routing
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // ResponseInfo contains the intended response code, the type of response that is
@@ -40,6 +47,26 @@ type ResponseInfo struct {
 	// doing something like loading an entire data file into memory if
 	// the responseType is of type Data.
 	Body io.Reader
+
+	// ctx is the context of the request that this is a response to.
+	// It is set by the router once the response reaches post process.
+	ctx context.Context
+
+	// mounted are the routers the response came through on its way out of
+	// a mount point, outermost first, whose response processors still have
+	// to run. See Router.Mount.
+	mounted []*Router
+
+	// err is the error that this is a response to, if it is an error
+	// response. See Error.
+	err *HTTPError
+
+	// request is the request that this is a response to. It is set by
+	// the router once the response reaches post process.
+	request *http.Request
+
+	// rendered is whether an ErrorRenderer has rendered this response.
+	rendered bool
 }
 
 // CreateResponseInfo creates a new ResponseInfo for use with the rest of the pipeline.
@@ -47,11 +74,11 @@ type ResponseInfo struct {
 // this is required.
 func CreateResponseInfo(code int, headers http.Header, responseType ResponseType, endpoint string, body io.Reader) ResponseInfo {
 	return ResponseInfo{
-		code,
-		headers,
-		responseType,
-		endpoint,
-		body,
+		code:         code,
+		Headers:      headers,
+		responseType: responseType,
+		endpoint:     endpoint,
+		Body:         body,
 	}
 }
 
@@ -64,6 +91,7 @@ func CreateGenericErrorResponse(code int, msg string) *ResponseInfo {
 		responseType: Text,
 		endpoint:     EndpointError,
 		Body:         bytes.NewBufferString(msg),
+		err:          NewHTTPError(code, msg, nil),
 	}
 }
 
@@ -75,12 +103,177 @@ func (i *ResponseInfo) Code() int {
 	return i.code
 }
 
+// Error returns the error that this is a response to, or nil if this isn't
+// an error response. In production mode, the internal cause of the error
+// is never included (see Router.SetProductionMode).
+func (i *ResponseInfo) Error() *HTTPError {
+	return i.err
+}
+
+// Context returns the context of the request that this is a response to.
+func (i *ResponseInfo) Context() context.Context {
+	if i.ctx == nil {
+		return context.Background()
+	}
+
+	return i.ctx
+}
+
+// Attributes returns the Attributes of the request that this is a response
+// to, as set by the earlier stages of the pipeline. This is nil if the
+// response isn't going through a Router.
+func (i *ResponseInfo) Attributes() *Attributes {
+	return AttributesFrom(i.Context())
+}
+
+// Finalize converts the response into ResponseData, filling in any headers
+// that can be derived from the response itself:
+//
+//   - Content-Type, from the response type. Data responses use the extension
+//     of the body's file name if it has one (e.g., *os.File), or otherwise
+//     sniff the first 512 bytes of the body through http.DetectContentType.
+//   - Content-Length, if the size of the body is known up front: the body
+//     either has a Len method (e.g., *bytes.Buffer), or is an io.Seeker.
+//
+// Headers that were already set by a handler or a response processor are
+// left alone, including headers that were set to nil to stop them from
+// being sent. The derived headers are filled into a copy of the response's
+// headers, since handlers may share one header map between responses.
+// None responses never have a body, even if one was given.
 func (i *ResponseInfo) Finalize() ResponseData {
+	headers := i.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+
+	body := i.Body
+
+	if i.responseType == None {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
+
+		return ResponseData{
+			Code:    i.code,
+			Headers: headers,
+		}
+	}
+
+	if _, ok := headers["Content-Length"]; !ok {
+		if length, ok := bodyLength(body); ok {
+			headers.Set("Content-Length", strconv.FormatInt(length, 10))
+		}
+	}
+
+	if _, ok := headers["Content-Type"]; !ok {
+		var contentType string
+		contentType, body = i.responseType.contentType(body)
+
+		if contentType != "" {
+			headers.Set("Content-Type", contentType)
+		}
+	}
+
 	return ResponseData{
 		Code:    i.code,
-		Headers: i.Headers,
-		Data:    i.Body,
+		Headers: headers,
+		Data:    body,
+	}
+}
+
+// contentType gets the content type for a body of this response type.
+// Since sniffing the content type has to read part of the body, the body
+// that should be used from now on is returned as well.
+func (t ResponseType) contentType(body io.Reader) (string, io.Reader) {
+	switch t {
+	case Html:
+		return "text/html; charset=utf-8", body
+	case Text:
+		return "text/plain; charset=utf-8", body
+	case Json:
+		return "application/json", body
+	case Data:
+		if named, ok := body.(interface{ Name() string }); ok {
+			if contentType := mime.TypeByExtension(filepath.Ext(named.Name())); contentType != "" {
+				return contentType, body
+			}
+		}
+
+		if body == nil {
+			return "", body
+		}
+
+		return sniffContentType(body)
+	}
+
+	return "", body
+}
+
+// sniffLen is how much of a body http.DetectContentType looks at.
+const sniffLen = 512
+
+// sniffContentType reads the start of the body to detect its content type,
+// and then returns a body that replays what was read.
+func sniffContentType(body io.Reader) (string, io.Reader) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, buf)
+	buf = buf[:n]
+
+	var rest io.Reader = body
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		// let send deal with the error once it gets past what was read
+		rest = errorReader{err}
+	}
+
+	replay := io.MultiReader(bytes.NewReader(buf), rest)
+
+	if closer, ok := body.(io.Closer); ok {
+		return http.DetectContentType(buf), readCloser{replay, closer}
+	}
+
+	return http.DetectContentType(buf), replay
+}
+
+// bodyLength gets the amount of bytes that are left in the body, if
+// that can be known without reading it.
+func bodyLength(body io.Reader) (int64, bool) {
+	switch b := body.(type) {
+	case nil:
+		return 0, true
+	case interface{ Len() int }:
+		return int64(b.Len()), true
+	case io.Seeker:
+		current, err := b.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+
+		end, err := b.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+
+		if _, err := b.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+
+		return end - current, true
 	}
+
+	return 0, false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errorReader struct {
+	err error
+}
+
+func (e errorReader) Read([]byte) (int, error) {
+	return 0, e.err
 }
 
 // ResponseData is the final leg of the pipeline, where it only contains
@@ -94,10 +287,26 @@ type ResponseData struct {
 	Data    io.Reader
 }
 
-// Send sends the response data over the given ResponseWriter. If an
-// error occurs during writing, it will write a http.StatusServiceUnavailable
-// into the response and then immediately stop writing any more data.
-func (data *ResponseData) send(w http.ResponseWriter) {
+// chunkPool holds the buffers that send reads each chunk into,
+// so that every response doesn't need a buffer of its own.
+var chunkPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, ChunkSize)
+		return &buf
+	},
+}
+
+// send sends the response data over the given ResponseWriter. The status
+// code and the headers are always committed first, and then the body is
+// streamed in chunks of ChunkSize. If the body can be closed, it is closed
+// once it has been sent. The amount of bytes of the body that were sent is
+// returned, even if an error occurred.
+//
+// Once the status code is committed, it can't be taken back, so if an error
+// occurs while streaming the body, the error is returned to the caller and
+// no more data is written. The caller should abort the connection, so that
+// the client can tell the response is incomplete.
+func (data *ResponseData) send(w http.ResponseWriter) (int64, error) {
 	headers := w.Header()
 
 	// add every single header into the set of headers
@@ -114,29 +323,44 @@ func (data *ResponseData) send(w http.ResponseWriter) {
 		}
 	}
 
-	buf := make([]byte, ChunkSize)
+	code := data.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	w.WriteHeader(code)
 
-	var b int
-	var readErr error
-	var writeErr error
+	if data.Data == nil {
+		return 0, nil
+	}
 
-	if data.Data != nil {
-		for readErr != io.EOF {
-			if b, readErr = data.Data.Read(buf); readErr != nil && readErr != io.EOF {
-				// error handle
-				w.WriteHeader(http.StatusServiceUnavailable)
-				return
-			}
+	if closer, ok := data.Data.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	buf := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(buf)
+
+	var sent int64
 
-			if _, writeErr = w.Write(buf[0:b]); writeErr != nil {
-				// error handle
-				w.WriteHeader(http.StatusServiceUnavailable)
-				return
+	for {
+		b, readErr := data.Data.Read(*buf)
+
+		if b > 0 {
+			n, err := w.Write((*buf)[:b])
+			sent += int64(n)
+
+			if err != nil {
+				return sent, err
 			}
 		}
-	}
 
-	w.WriteHeader(data.Code)
+		if readErr == io.EOF {
+			return sent, nil
+		} else if readErr != nil {
+			return sent, readErr
+		}
+	}
 }
 
 type ResponseType int
@@ -158,3 +382,33 @@ const (
 	// through the response.
 	None
 )
+
+// responseTypeNames are the names of every response type, as used by
+// String and ParseResponseType.
+var responseTypeNames = map[ResponseType]string{
+	Html: "html",
+	Text: "text",
+	Json: "json",
+	Data: "data",
+	None: "none",
+}
+
+func (t ResponseType) String() string {
+	if name, ok := responseTypeNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ResponseType(%d)", int(t))
+}
+
+// ParseResponseType returns the response type with the given name, as returned
+// by ResponseType.String. Names are not case-sensitive.
+func ParseResponseType(name string) (ResponseType, error) {
+	for t, n := range responseTypeNames {
+		if strings.EqualFold(n, name) {
+			return t, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown response type %q", name)
+}
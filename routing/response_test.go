@@ -0,0 +1,217 @@
+package routing
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// responseRoute responds with whatever response it was given.
+type responseRoute struct {
+	resp ResponseInfo
+}
+
+func (r *responseRoute) HandleRequest(*RequestInfo) (*ResponseInfo, error) {
+	resp := r.resp
+	return &resp, nil
+}
+
+// failingReader reads some data, and then fails.
+type failingReader struct {
+	data   io.Reader
+	closed bool
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("disk on fire")
+	}
+
+	return n, err
+}
+
+func (f *failingReader) Close() error {
+	f.closed = true
+	return nil
+}
+
+func routeTo(router *Router, w http.ResponseWriter, rawUrl string) {
+	testUrl, _ := url.Parse(rawUrl)
+	router.RouteRequest(w, &http.Request{
+		Method: http.MethodGet,
+		URL:    testUrl,
+	})
+}
+
+func TestResponseData_sendCommitsStatusFirst(t *testing.T) {
+	router := NewRouter()
+	router.RegisterRoute("missing", &responseRoute{CreateResponseInfo(
+		http.StatusNotFound,
+		http.Header{"X-Test": {"yes"}},
+		Text,
+		"missing",
+		strings.NewReader(strings.Repeat("a", ChunkSize*2+1)),
+	)})
+
+	w := httptest.NewRecorder()
+	routeTo(router, w, "https://test.org/missing/")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected http.StatusNotFound, got %d", w.Code)
+	}
+
+	if w.Header().Get("X-Test") != "yes" {
+		t.Fatalf("expected X-Test header to be sent")
+	}
+
+	if w.Body.Len() != ChunkSize*2+1 {
+		t.Fatalf("expected the full body to be sent, got %d bytes", w.Body.Len())
+	}
+}
+
+func TestResponseData_sendAbortsOnReadError(t *testing.T) {
+	body := &failingReader{data: strings.NewReader("partial")}
+
+	router := NewRouter()
+	router.RegisterRoute("fail", &responseRoute{CreateResponseInfo(http.StatusOK, http.Header{}, Data, "fail", body)})
+
+	w := httptest.NewRecorder()
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("expected panic with http.ErrAbortHandler, got %v", p)
+		}
+
+		if w.Code != http.StatusOK || w.Body.String() != "partial" {
+			t.Fatalf("expected status and partial body to be committed, got %d and %s", w.Code, w.Body.String())
+		}
+
+		if !body.closed {
+			t.Fatalf("expected body to be closed")
+		}
+	}()
+
+	routeTo(router, w, "https://test.org/fail/")
+}
+
+func TestResponseData_sendClosesBody(t *testing.T) {
+	body := &failingReader{data: strings.NewReader("")}
+	data := &ResponseData{Code: http.StatusOK, Data: struct {
+		io.Reader
+		io.Closer
+	}{strings.NewReader("done"), body}}
+
+	w := httptest.NewRecorder()
+	if _, err := data.send(w); err != nil {
+		t.Fatalf("unexpected error on send: %s", err)
+	}
+
+	if !body.closed || w.Body.String() != "done" {
+		t.Fatalf("expected body to be sent and closed")
+	}
+}
+
+func TestResponseInfo_FinalizeHeaders(t *testing.T) {
+	dir := t.TempDir()
+	cssPath := filepath.Join(dir, "style.css")
+	if err := os.WriteFile(cssPath, []byte("body {}"), 0o644); err != nil {
+		t.Fatalf("error upon writing file: %s", err)
+	}
+
+	css, err := os.Open(cssPath)
+	if err != nil {
+		t.Fatalf("error upon opening file: %s", err)
+	}
+	defer css.Close()
+
+	png := "\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 600)
+
+	testValues := []struct {
+		name          string
+		responseType  ResponseType
+		headers       http.Header
+		body          io.Reader
+		contentType   string
+		contentLength string
+	}{
+		{"html", Html, nil, strings.NewReader("<p></p>"), "text/html; charset=utf-8", "7"},
+		{"text", Text, nil, bytes.NewBufferString("hi"), "text/plain; charset=utf-8", "2"},
+		{"json", Json, http.Header{}, strings.NewReader("{}"), "application/json", "2"},
+		{"extension", Data, http.Header{}, css, "text/css; charset=utf-8", "7"},
+		{"sniffed", Data, http.Header{}, io.MultiReader(strings.NewReader(png)), "image/png", ""},
+		{"override", Html, http.Header{"Content-Type": {"application/xhtml+xml"}}, strings.NewReader("<p></p>"), "application/xhtml+xml", "7"},
+		{"none", None, http.Header{}, strings.NewReader("ignored"), "", ""},
+	}
+
+	for _, v := range testValues {
+		resp := CreateResponseInfo(http.StatusOK, v.headers, v.responseType, "test", v.body)
+		data := resp.Finalize()
+
+		w := httptest.NewRecorder()
+		if _, err := data.send(w); err != nil {
+			t.Fatalf("%s: unexpected error on send: %s", v.name, err)
+		}
+
+		if w.Header().Get("Content-Type") != v.contentType {
+			t.Errorf("%s: expected Content-Type %q, got %q", v.name, v.contentType, w.Header().Get("Content-Type"))
+		}
+
+		if w.Header().Get("Content-Length") != v.contentLength {
+			t.Errorf("%s: expected Content-Length %q, got %q", v.name, v.contentLength, w.Header().Get("Content-Length"))
+		}
+
+		if v.responseType == None && w.Body.Len() != 0 {
+			t.Errorf("%s: expected no body, got %s", v.name, w.Body.String())
+		}
+
+		if v.name == "sniffed" && w.Body.String() != png {
+			t.Errorf("%s: expected sniffed body to be sent in full", v.name)
+		}
+	}
+}
+
+func TestResponseInfo_FinalizeSharedHeaders(t *testing.T) {
+	shared := http.Header{"X-Test": {"yes"}}
+
+	router := NewRouter()
+	router.RegisterRoute("short", &responseRoute{CreateResponseInfo(
+		http.StatusOK, shared, Text, "short", strings.NewReader("short"),
+	)})
+	router.RegisterRoute("long", &responseRoute{CreateResponseInfo(
+		http.StatusOK, shared, Text, "long", strings.NewReader("a much longer body"),
+	)})
+
+	testValues := []struct {
+		path string
+		body string
+	}{
+		{"https://test.org/short/", "short"},
+		{"https://test.org/long/", "a much longer body"},
+	}
+
+	for _, v := range testValues {
+		w := httptest.NewRecorder()
+		routeTo(router, w, v.path)
+
+		if w.Body.String() != v.body {
+			t.Fatalf("%s: expected body %q, got %q", v.path, v.body, w.Body.String())
+		}
+
+		if w.Header().Get("Content-Length") != strconv.Itoa(len(v.body)) {
+			t.Fatalf("%s: expected Content-Length %d, got %q", v.path, len(v.body), w.Header().Get("Content-Length"))
+		}
+	}
+
+	if len(shared) != 1 {
+		t.Fatalf("expected the shared headers to be left alone, got %v", shared)
+	}
+}
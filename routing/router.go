@@ -1,9 +1,13 @@
 package routing
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"time"
 )
 
 // Router is the core of den, it is what links the request to the module.
@@ -19,6 +23,10 @@ type Router struct {
 	// registered module.
 	routes map[string]RouteHandler
 
+	// Map of patterns to endpoints. These are tried before
+	// the routes of the same endpoint, see RegisterPattern.
+	patterns map[string]*routeTable
+
 	// Map of request processors to HTTP methods.
 	requestProcessors map[string][]RequestProcessor
 
@@ -26,6 +34,37 @@ type Router struct {
 	// for anything that occurs after the data is processed
 	// by the endpoint handler.
 	responseProcessors map[ResponseType]responseProcessors
+
+	// middleware wraps the handler of every request, see Use.
+	middleware []Middleware
+
+	// hosts resolves the endpoint of a request from its host, see SetHostResolver.
+	hosts *HostResolver
+
+	// errorRenderers are the renderers of error responses, by status code
+	// and content type. See RegisterErrorRenderer.
+	errorRenderers map[int]map[string]ErrorRenderer
+
+	// observers are notified of every stage of every request,
+	// see RegisterObserver.
+	observers []Observer
+
+	// panicHandler is called whenever a stage panics, see SetPanicHandler.
+	panicHandler PanicHandler
+
+	// production is whether the router is in production mode,
+	// see SetProductionMode.
+	production bool
+
+	// errorLog is where internal errors are logged, see SetErrorLog.
+	errorLog *log.Logger
+
+	// timeout is how long a single request may take, see SetTimeout.
+	timeout time.Duration
+
+	// drain tracks the requests that are currently inside the
+	// router, so that they can be waited on during shutdown.
+	drain drainState
 }
 
 func NewRouter() *Router {
@@ -79,13 +118,14 @@ type ResponseProcessor interface {
 	ProcessResponse(resp *ResponseInfo) error
 }
 
-// RegisterRoute registers an endpoint to a route handler.
-func (r *Router) RegisterRoute(route string, handler RouteHandler) {
+// RegisterRoute registers an endpoint to a route handler. Any middleware
+// given only wraps this route, see Chain.
+func (r *Router) RegisterRoute(route string, handler RouteHandler, middleware ...Middleware) {
 	if r.routes == nil {
 		r.routes = make(map[string]RouteHandler)
 	}
 
-	r.routes[route] = handler
+	r.routes[route] = Chain(handler, middleware...)
 }
 
 // RegisterRequestProcessor registers an HTTP method to a request processor.
@@ -121,16 +161,15 @@ func (r *Router) getRouteHandler(endpoint string) (RouteHandler, error) {
 		return handler, nil
 	}
 
-	return nil, errors.New("could not retrieve handler")
+	return nil, NewHTTPError(http.StatusNotFound, "", nil)
 }
 
 func (r *Router) getRequestProcessors(method string) ([]RequestProcessor, error) {
 	if handlers, ok := r.requestProcessors[method]; ok {
 		return handlers, nil
-	} else {
-		r.requestProcessors[method] = make([]RequestProcessor, 0)
-		return r.requestProcessors[method], nil
 	}
+
+	return make([]RequestProcessor, 0), nil
 }
 
 func (r *Router) getResponseProcessors(responseType ResponseType, endpoint string) ([]ResponseProcessor, error) {
@@ -145,48 +184,111 @@ func (r *Router) getResponseProcessors(responseType ResponseType, endpoint strin
 	return nil, errors.New("no handlers registered for this method")
 }
 
-func (r *Router) preProcessRequest(ctx *routingContext, req *http.Request) error {
-	var err error
-	if handler, err := r.getRequestProcessors(req.Method); err == nil {
-		for _, h := range handler {
-			err := h.ProcessRequest(req)
-			if err != nil {
-				ctx.CloseWithError(err)
-			}
-		}
-	} else {
+func (r *Router) preProcessRequest(ctx *routingContext, req *http.Request) (*ResponseInfo, error) {
+	resp, err := r.runRequestProcessors(req)
+	if err != nil {
 		ctx.CloseWithError(err)
-		return err
+		return nil, err
 	}
 
-	return err
+	return resp, nil
 }
 
-func (r *Router) handleRequest(ctx *routingContext, req *http.Request) (*ResponseInfo, error) {
-	info := NewRequestInfo(req)
+// runRequestProcessors runs every request processor registered to the
+// request's method, stopping at the first error, or at the first response
+// from a RequestResponder.
+func (r *Router) runRequestProcessors(req *http.Request) (*ResponseInfo, error) {
+	handlers, err := r.getRequestProcessors(req.Method)
+	if err != nil {
+		return nil, err
+	}
 
-	if handler, err := r.getRouteHandler(info.requestEndpoint); err == nil {
-		resp, err := handler.HandleRequest(info)
+	for _, h := range handlers {
+		if responder, ok := h.(RequestResponder); ok {
+			resp, err := responder.RespondRequest(req)
+			if err != nil || resp != nil {
+				return resp, err
+			}
 
-		if err != nil {
-			ctx.CloseWithError(err)
+			continue
 		}
 
-		return resp, err
+		if err := h.ProcessRequest(req); err != nil {
+			return nil, err
+		}
 	}
 
-	// TODO error
-	return nil, errors.New("could not find endpoint")
+	return nil, nil
 }
 
-func (r *Router) processResponse(ctx *routingContext, resp *ResponseInfo) (*ResponseData, error) {
-	if handlers, err := r.getResponseProcessors(resp.ResponseType(), resp.endpoint); err == nil {
-		for _, h := range handlers {
-			err := h.ProcessResponse(resp)
-			if err != nil {
-				ctx.CloseWithError(err)
-				return nil, err
-			}
+// getHandler gets the handler for the request's endpoint, trying the
+// patterns of the endpoint before its route, and the key of the route that
+// it was found under, which is either the endpoint, or EndpointDefault.
+func (r *Router) getHandler(info *RequestInfo) (RouteHandler, string, error) {
+	if handler, err := r.getPatternHandler(info); err == nil {
+		return handler, info.requestEndpoint, nil
+	}
+
+	if handler, ok := r.routes[info.requestEndpoint]; ok {
+		return handler, info.requestEndpoint, nil
+	}
+
+	handler, err := r.getRouteHandler(EndpointDefault)
+
+	return handler, EndpointDefault, err
+}
+
+func (r *Router) handleRequest(p *pipeline) (*ResponseInfo, error) {
+	ctx := p.ctx
+
+	info, table := newRequestInfo(p.req, r.hostResolver())
+	if table == nil {
+		table = r
+	}
+
+	p.endpoint = info.requestEndpoint
+
+	handler, route, err := table.getHandler(info)
+	if err != nil {
+		ctx.CloseWithError(err)
+		return nil, err
+	}
+
+	p.route, p.routed = route, true
+
+	resp, err := Chain(handler, r.middleware...).HandleRequest(info)
+	if err == nil && resp == nil {
+		err = errors.New("handler returned no response")
+	}
+
+	if err != nil {
+		ctx.CloseWithError(err)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *Router) processResponse(ctx *routingContext, req *http.Request, resp *ResponseInfo) (*ResponseData, error) {
+	resp.ctx = ctx
+	resp.request = req
+
+	routers := append([]*Router{r}, resp.mounted...)
+
+	// errors are rendered before any processor sees them
+	if resp.endpoint == EndpointError {
+		if err := renderError(routers, resp); err != nil {
+			ctx.CloseWithError(err)
+			return nil, err
+		}
+	}
+
+	// the processors of any mounted routers the response came
+	// through run after this router's, from the outside in
+	for _, router := range routers {
+		if err := router.runResponseProcessors(resp); err != nil {
+			ctx.CloseWithError(err)
+			return nil, err
 		}
 	}
 
@@ -195,67 +297,226 @@ func (r *Router) processResponse(ctx *routingContext, resp *ResponseInfo) (*Resp
 	return &res, nil
 }
 
-// routeStage is an indicator of what stage the router is going through.
+// runResponseProcessors runs every response processor registered to the
+// response's type and endpoint, stopping at the first error.
+func (r *Router) runResponseProcessors(resp *ResponseInfo) error {
+	handlers, _ := r.getResponseProcessors(resp.ResponseType(), resp.endpoint)
+
+	for _, h := range handlers {
+		if err := h.ProcessResponse(resp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stage is an indicator of what stage the router is going through.
 // This is essentially a linear state machine; this is so that Contexts
 // can be cancelled in between requests
-type routeStage int
+type Stage int
 
 const (
-	initial routeStage = iota
-	routing
-	postProcess
-	send
-	finish
+	// StagePreProcess runs the request processors.
+	StagePreProcess Stage = iota
+	// StageRouting routes the request to its handler.
+	StageRouting
+	// StagePostProcess runs the response processors, and
+	// finalizes the response.
+	StagePostProcess
+	// StageSend sends the response to the client.
+	StageSend
+	stageFinish
 )
 
-func (r *Router) processRequest(ctx *routingContext, w http.ResponseWriter, req *http.Request) {
-	var err error
-	switch ctx.stage {
-	case initial:
-		err = r.preProcessRequest(ctx, req)
-	case routing:
-		ctx.info, err = r.handleRequest(ctx, req)
-	case postProcess:
-		ctx.data, err = r.processResponse(ctx, ctx.info)
-	case send:
-		ctx.data.send(w)
+// stageNames are the names of every stage, as used by String.
+var stageNames = map[Stage]string{
+	StagePreProcess:  "preProcess",
+	StageRouting:     "routing",
+	StagePostProcess: "postProcess",
+	StageSend:        "send",
+}
+
+func (s Stage) String() string {
+	if name, ok := stageNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Stage(%d)", int(s))
+}
+
+// pipeline is the state of a single request going through the router.
+type pipeline struct {
+	ctx *routingContext
+	w   http.ResponseWriter
+	req *http.Request
+
+	stage Stage
+	info  *ResponseInfo
+	data  *ResponseData
+
+	// endpoint is the endpoint the request was routed to, and
+	// received is when the router received the request
+	endpoint string
+	received time.Time
+
+	// route is the key of the route that handled the request, if
+	// routed is set, see StageEvent.Route
+	route  string
+	routed bool
+
+	// failed is whether the response was replaced with an error response,
+	// in which case it is forced through to the client no matter what
+	failed bool
+
+	// sent is the amount of bytes of the body that were sent, and
+	// sendErr is the error that occurred while sending the response,
+	// if any. See ResponseData.send.
+	sent    int64
+	sendErr error
+}
+
+// step runs the stage that the pipeline is currently on, and moves the
+// pipeline on to the next stage. If the stage fails, or the context was
+// cancelled before it could run, the pipeline is sent back to
+// StagePostProcess with an error response instead.
+func (r *Router) step(p *pipeline) {
+	if p.stage < StageSend && !p.failed {
+		if err := p.ctx.Err(); err != nil {
+			r.fail(p, err)
+			return
+		}
+	}
+
+	stage, start := p.stage, time.Now()
+	r.stageStart(p, stage)
+
+	err := r.recoverStage(p.ctx, p.req, func() error {
+		var err error
+
+		switch p.stage {
+		case StagePreProcess:
+			p.info, err = r.preProcessRequest(p.ctx, p.req)
+
+			// a request processor responded, so skip routing
+			if p.info != nil {
+				p.stage = StageRouting
+			}
+		case StageRouting:
+			p.info, err = r.handleRequest(p)
+		case StagePostProcess:
+			p.data, err = r.processResponse(p.ctx, p.req, p.info)
+		case StageSend:
+			p.sent, err = p.data.send(p.w)
+		}
+
+		return err
+	})
+
+	r.stageEnd(p, stage, start, err)
+
+	switch {
+	case err == nil:
+		p.stage++
+	case p.stage == StageSend:
+		// the response can't be taken back at this point,
+		// so RouteRequest aborts the connection instead
+		p.sendErr = err
+		p.stage = stageFinish
+	case p.stage == StagePostProcess && p.failed:
+		// the error response itself failed to process,
+		// so send it as it is
+		res := p.info.Finalize()
+		p.data = &res
+		p.stage = StageSend
+	default:
+		r.fail(p, err)
 	}
+}
 
-	// if there's no error, or the context already
-	// has a failed state, upgrade the state
+// fail replaces the response of the pipeline with an error response for
+// the given error, and sends the pipeline back to StagePostProcess.
+func (r *Router) fail(p *pipeline, err error) {
+	p.abandon(r.errorResponse(p.req, err))
+}
 
-	// the reasoning behind context stage upgrades
-	// with a failed state is to ensure that the
-	// data is forced through all stages until it
-	// reaches the client (as the contained response
-	// should now be a generic error)
-	if err == nil || ctx.Err() != nil {
-		ctx.upgradeStage()
+// abandon replaces the response of the pipeline, and sends
+// the pipeline back to StagePostProcess. The body of the
+// response that was replaced is closed, if it can be.
+func (p *pipeline) abandon(info *ResponseInfo) {
+	if p.info != nil {
+		if closer, ok := p.info.Body.(io.Closer); ok {
+			closer.Close()
+		}
 	}
+
+	p.info = info
+	p.data = nil
+	p.failed = true
+	p.stage = StagePostProcess
 }
 
 // RouteRequest is a function that routes a request into the router tables.
-// The request is routed through in a goroutine, and then blocked until either
-// the context is cancelled (most likely due to an error), or until the 'finish'
-// stage is reached in the routing state.
+// Each stage of the request (processing the request, routing it, processing
+// the response, and sending it) runs in turn on the calling goroutine. If the
+// context is cancelled before the response is sent (most likely due to an
+// error, a timeout, or the client going away), the rest of the stages are
+// skipped, and an error response is sent instead (see HTTPError).
+//
+// If the router is draining (see Drain), the request skips straight to the
+// StagePostProcess with a generic http.StatusServiceUnavailable response.
+//
+// A panic in any stage is recovered, and turned into an error response (see
+// PanicError), except for a panic with http.ErrAbortHandler, which is passed
+// on as it is. If the response fails partway through being sent, or a panic
+// occurs while it's being sent, RouteRequest panics with
+// http.ErrAbortHandler, which net/http handles by aborting the connection.
 func (r *Router) RouteRequest(w http.ResponseWriter, req *http.Request) {
-	ctx := newRoutingContext()
-
-	// loop through until the route reaches the final state
-	// even with an error, it will always reach the final state
-	for ctx.stage != finish {
-		go r.processRequest(ctx, w, req)
-
-		select {
-		case <-ctx.Done():
-			if ctx.stage != finish {
-				ctx.info = CreateGenericErrorResponse(http.StatusServiceUnavailable, fmt.Sprint(ctx.Err()))
-				ctx.stage = postProcess
-			}
-		case s := <-ctx.stageChan:
-			ctx.stage = s
-		}
+	parent := req.Context()
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		parent, cancel = context.WithTimeout(parent, r.timeout)
+		defer cancel()
 	}
+
+	ctx := newRoutingContext(parent)
+	defer ctx.cancel()
+
+	// everything down the pipeline sees the routing context
+	p := &pipeline{
+		ctx:      ctx,
+		w:        w,
+		req:      req.WithContext(ctx),
+		received: time.Now(),
+	}
+
+	if r.acquire() {
+		defer r.release()
+	} else {
+		info := CreateGenericErrorResponse(http.StatusServiceUnavailable, "server is shutting down")
+		info.Headers = http.Header{"Connection": {"close"}}
+		p.abandon(info)
+	}
+
+	for p.stage != stageFinish {
+		r.step(p)
+	}
+
+	// the response was cut off partway through, so make sure
+	// that the client can tell by aborting the connection
+	if p.sendErr != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// SetTimeout sets how long a single request may take to go through the
+// router. Once the timeout has passed, the request's context is cancelled,
+// and a generic error is sent instead. Stages still run on the goroutine
+// serving the request, so a stage that ignores its context holds up the
+// response until it returns, after which its result is thrown away. A zero
+// timeout never times out.
+func (r *Router) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
 }
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
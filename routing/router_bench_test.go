@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// benchRoute responds with a short text body.
+type benchRoute struct{}
+
+func (benchRoute) HandleRequest(*RequestInfo) (*ResponseInfo, error) {
+	resp := CreateResponseInfo(http.StatusOK, nil, Text, "bench", bytes.NewBufferString("Hello, world!"))
+	return &resp, nil
+}
+
+// discardWriter is an http.ResponseWriter that throws the response away,
+// so that only the allocations of the router itself are counted.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+func benchmarkRouter(b *testing.B, router *Router, rawUrl string) {
+	req, _ := http.NewRequest(http.MethodGet, rawUrl, nil)
+	w := &discardWriter{make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for k := range w.header {
+			delete(w.header, k)
+		}
+
+		router.RouteRequest(w, req)
+	}
+}
+
+func BenchmarkRouter_RouteRequest(b *testing.B) {
+	router := NewRouter()
+	router.RegisterRoute("bench", benchRoute{})
+
+	benchmarkRouter(b, router, "https://test.org/bench/")
+}
+
+func BenchmarkRouter_RouteRequestPattern(b *testing.B) {
+	router := NewRouter()
+	router.RegisterPattern(http.MethodGet, "/bench/users/{id}", benchRoute{})
+
+	benchmarkRouter(b, router, "https://test.org/bench/users/42")
+}
+
+func BenchmarkRouter_RouteRequestProcessors(b *testing.B) {
+	router := NewRouter()
+	router.RegisterRoute("bench", benchRoute{})
+	router.RegisterRequestProcessor(http.MethodGet, &alwaysError{})
+	router.RegisterResponseProcessor(Text, "bench", &alwaysError{})
+
+	benchmarkRouter(b, router, "https://test.org/bench/")
+}
+
+func BenchmarkRouter_RouteRequestTimeout(b *testing.B) {
+	router := NewRouter()
+	router.SetTimeout(time.Minute)
+	router.RegisterRoute("bench", benchRoute{})
+
+	benchmarkRouter(b, router, "https://test.org/bench/")
+}
+
+func BenchmarkRouter_RouteRequestError(b *testing.B) {
+	router := NewRouter()
+
+	benchmarkRouter(b, router, "https://test.org/missing/")
+}
@@ -2,11 +2,12 @@ package routing
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
-	"strings"
 	"testing"
 )
 
@@ -90,7 +91,14 @@ func (a *alwaysError) HandleRequest(*RequestInfo) (*ResponseInfo, error) {
 func TestRouter_RegisterRoute(t *testing.T) {
 	router := new(Router)
 	handler := new(testRoute)
-	req := new(RequestInfo)
+	handler.endpoint = "test"
+	handler.method = http.MethodGet
+
+	testUrl, _ := url.Parse("https://test.org/test/")
+	req := NewRequestInfo(&http.Request{
+		Method: http.MethodGet,
+		URL:    testUrl,
+	})
 
 	router.RegisterRoute("test", handler)
 
@@ -100,9 +108,13 @@ func TestRouter_RegisterRoute(t *testing.T) {
 		t.Fatalf("Failed to get route handler for endpoint test: %s", err)
 	}
 
-	info, _ := routeHandler.HandleRequest(req)
+	info, err := routeHandler.HandleRequest(req)
+
+	if err != nil {
+		t.Fatalf("Failed to handle request for endpoint test: %s", err)
+	}
 
-	if info.endpoint != "test" && info.code != http.StatusOK {
+	if info.endpoint != "test" || info.code != http.StatusOK {
 		t.Fatalf("RegisterRoute(\"test\", handler): expected endpoint test, code 200, got %s, %d", info.endpoint, info.code)
 	}
 }
@@ -141,27 +153,7 @@ func TestRouter_RegisterResponseProcessor(t *testing.T) {
 	}
 }
 
-type dummyWriter struct {
-	data    bytes.Buffer
-	headers http.Header
-	code    int
-	final   []byte
-}
-
-func (d *dummyWriter) Write(i []byte) (int, error) {
-	return d.data.Write(i)
-}
-
-func (d *dummyWriter) WriteHeader(statusCode int) {
-	d.code = statusCode
-	d.final = d.data.Bytes()
-}
-
-func (d *dummyWriter) Header() http.Header {
-	return d.headers
-}
-
-func TestRouter_processRequest(t *testing.T) {
+func TestRouter_step(t *testing.T) {
 	router := new(Router)
 
 	handler := new(testRoute)
@@ -173,68 +165,64 @@ func TestRouter_processRequest(t *testing.T) {
 	router.RegisterRoute(handler.endpoint, handler)
 	router.RegisterResponseProcessor(handler.responseType, handler.endpoint, handler)
 
-	ctx := newRoutingContext()
+	ctx := newRoutingContext(context.Background())
 	testUrl, _ := url.Parse("https://test.org/endpoint/path/")
-	req := &http.Request{
-		Method: http.MethodGet,
-		URL:    testUrl,
-	}
-	writer := new(dummyWriter)
-
-	checkAdvance := func(stage routeStage) {
-		select {
-		case <-ctx.Done():
+	writer := httptest.NewRecorder()
+	p := &pipeline{
+		ctx: ctx,
+		w:   writer,
+		req: &http.Request{
+			Method: http.MethodGet,
+			URL:    testUrl,
+		},
+	}
+
+	checkAdvance := func(stage Stage) {
+		if ctx.Err() != nil {
 			t.Fatalf("context contains error: %s", ctx.Err())
-		case s := <-ctx.stageChan:
-			if s != stage {
-				t.Fatalf("got different stage than expected: expected %d, got %d", stage, s)
-			}
+		}
 
-			ctx.stage = s
+		if p.stage != stage {
+			t.Fatalf("got different stage than expected: expected %d, got %d", stage, p.stage)
 		}
 	}
 
-	router.processRequest(ctx, writer, req)
-	checkAdvance(routing)
+	router.step(p)
+	checkAdvance(StageRouting)
 
-	router.processRequest(ctx, writer, req)
+	router.step(p)
 
-	if ctx.info == nil {
-		t.Fatalf("expected info in context to be populated, got nil")
+	if p.info == nil {
+		t.Fatalf("expected info in pipeline to be populated, got nil")
 	}
 
-	if ctx.info.ResponseType() != Text {
+	if p.info.ResponseType() != Text {
 		t.Fatalf("expected Text response type")
 	}
 
-	checkAdvance(postProcess)
+	checkAdvance(StagePostProcess)
 
-	router.processRequest(ctx, writer, req)
+	router.step(p)
 
-	if ctx.data == nil {
-		t.Fatalf("expected data in context to be populated, got nil")
+	if p.data == nil {
+		t.Fatalf("expected data in pipeline to be populated, got nil")
 	}
 
-	checkAdvance(send)
+	checkAdvance(StageSend)
 
-	router.processRequest(ctx, writer, req)
-	checkAdvance(finish)
+	router.step(p)
+	checkAdvance(stageFinish)
 
-	if writer.code != http.StatusOK {
-		t.Fatalf("expected code to be http.StatusOK, got %d", writer.code)
+	if writer.Code != http.StatusOK {
+		t.Fatalf("expected code to be http.StatusOK, got %d", writer.Code)
 	}
 
-	var s strings.Builder
-	if _, err := s.Write(writer.final); err != nil {
-		t.Fatalf("error writing to string builder: %s", err)
-	}
-
-	if s.String() != "Hello, world!" {
-		t.Fatalf("expected Hello, world! in final body, got %s", s.String())
+	if writer.Body.String() != "Hello, world!" {
+		t.Fatalf("expected Hello, world! in final body, got %s", writer.Body.String())
 	}
 }
 
-func TestRouter_processRequestWithErrors(t *testing.T) {
+func TestRouter_stepWithErrors(t *testing.T) {
 	router := new(Router)
 
 	handler := new(alwaysError)
@@ -242,20 +230,26 @@ func TestRouter_processRequestWithErrors(t *testing.T) {
 
 	router.RegisterRequestProcessor(http.MethodGet, handler)
 
-	ctx := newRoutingContext()
-	req := new(http.Request)
-	req.Method = http.MethodGet
-	writer := new(dummyWriter)
-
-	advance := func() {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ctx.stageChan:
-			t.Fatalf("expected error, got success")
-		}
+	ctx := newRoutingContext(context.Background())
+	testUrl, _ := url.Parse("https://test.org/")
+	p := &pipeline{
+		ctx: ctx,
+		w:   httptest.NewRecorder(),
+		req: &http.Request{
+			Method: http.MethodGet,
+			URL:    testUrl,
+		},
+	}
+
+	router.step(p)
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatalf("expected error, got success")
 	}
 
-	router.processRequest(ctx, writer, req)
-	advance()
+	if p.stage != StagePostProcess || !p.failed || p.info.Code() != http.StatusInternalServerError {
+		t.Fatalf("expected an error response in postProcess, got stage %d with %v", p.stage, p.info)
+	}
 }
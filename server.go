@@ -1,18 +1,33 @@
 package den
 
 import (
+	"context"
 	"den/routing"
+	"errors"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// DefaultShutdownTimeout is the amount of time a Server created through
+// NewServer will wait for in-flight requests to drain before giving up.
+const DefaultShutdownTimeout = 30 * time.Second
+
 type Server struct {
 	httpServer *http.Server
 	Router     *routing.Router
+
+	// ShutdownTimeout is how long ListenAndServe and Serve will wait for
+	// in-flight requests to finish once their context is cancelled. A zero
+	// value waits forever.
+	ShutdownTimeout time.Duration
 }
 
 func NewServer(ip net.Addr) *Server {
-	router := new(routing.Router)
+	router := routing.NewRouter()
 	httpServer := &http.Server{
 		Addr:    ip.String(),
 		Handler: router,
@@ -20,7 +35,104 @@ func NewServer(ip net.Addr) *Server {
 	serv := &Server{
 		httpServer,
 		router,
+		DefaultShutdownTimeout,
 	}
 
 	return serv
 }
+
+// Addr returns the address that this server listens on.
+func (s *Server) Addr() string {
+	return s.httpServer.Addr
+}
+
+// ListenAndServe listens on the server's TCP address, and then serves
+// requests through Serve until either the listener fails, or the given
+// context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, l)
+}
+
+// Serve accepts connections on the given listener until either the listener
+// fails, or the given context is cancelled. Once the context is cancelled,
+// the server is gracefully shut down, waiting up to ShutdownTimeout for any
+// in-flight requests to drain. A clean shutdown returns nil.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- s.httpServer.Serve(l)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// someone else called Shutdown on us
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx := context.Background()
+	if s.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.ShutdownTimeout)
+		defer cancel()
+	}
+
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown gracefully stops the server. The listeners are closed, the router
+// starts draining (new requests on existing connections are answered with
+// http.StatusServiceUnavailable), and then Shutdown blocks until every
+// in-flight request has gone through the router's send stage, or until
+// the given context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	drainErr := make(chan error, 1)
+
+	go func() {
+		drainErr <- s.Router.Drain(ctx)
+	}()
+
+	s.httpServer.SetKeepAlivesEnabled(false)
+	err := s.httpServer.Shutdown(ctx)
+
+	if e := <-drainErr; err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// ListenAndServeUntilSignal is ListenAndServe, but the server is shut down
+// once the process receives either SIGINT or SIGTERM.
+func (s *Server) ListenAndServeUntilSignal() error {
+	ctx, stop := SignalContext(context.Background())
+	defer stop()
+
+	return s.ListenAndServe(ctx)
+}
+
+// SignalContext returns a copy of the parent context that is cancelled once
+// the process receives either SIGINT or SIGTERM, which is usually what is
+// sent to a service during deploys. Calling the returned function stops
+// listening for those signals.
+func SignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,102 @@
+package den
+
+import (
+	"bytes"
+	"context"
+	"den/routing"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type slowRoute struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowRoute) HandleRequest(req *routing.RequestInfo) (*routing.ResponseInfo, error) {
+	close(s.started)
+	<-s.release
+
+	resp := routing.CreateResponseInfo(http.StatusOK, http.Header{}, routing.Text, req.RequestEndpoint(), bytes.NewBufferString("finished"))
+	return &resp, nil
+}
+
+func TestServer_ServeDrainsOnCancel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error upon listen: %s", err)
+	}
+
+	server := NewServer(l.Addr())
+	handler := &slowRoute{make(chan struct{}), make(chan struct{})}
+	server.Router.RegisterRoute("slow", handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx, l)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/slow/")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-handler.started
+	cancel()
+
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned before the in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(handler.release)
+
+	if b := <-body; b != "finished" {
+		t.Fatalf("expected in-flight request to finish, got %s", b)
+	}
+
+	if err := <-served; err != nil {
+		t.Fatalf("expected clean shutdown, got %s", err)
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error upon listen: %s", err)
+	}
+
+	server := NewServer(l.Addr())
+	server.ShutdownTimeout = 10 * time.Millisecond
+	handler := &slowRoute{make(chan struct{}), make(chan struct{})}
+	server.Router.RegisterRoute("slow", handler)
+	defer close(handler.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx, l)
+	}()
+
+	go http.Get("http://" + l.Addr().String() + "/slow/")
+
+	<-handler.started
+	cancel()
+
+	if err := <-served; err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}